@@ -0,0 +1,17 @@
+package main
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// getaffin returns the number of CPUs in the affinity mask of process pid (0
+// for the current process) and the raw mask.
+func getaffin(pid int) (int, string, error) {
+	cpuset := &unix.CPUSet{}
+	if err := unix.SchedGetaffinity(pid, cpuset); err != nil {
+		return 0, "", err
+	}
+	return cpuset.Count(), fmt.Sprintf("%v", *cpuset), nil
+}
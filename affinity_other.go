@@ -0,0 +1,10 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+// getaffin is only implemented on Linux.
+func getaffin(pid int) (int, string, error) {
+	return 0, "", errors.New("sched_getaffinity(2) unsupported on this platform")
+}
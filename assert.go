@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+)
+
+// assertCPUs records in r whether the effective CPU limit is within
+// tolerance of expected, where an expected 0 means no limit, e.g. to catch
+// an accidental change to a manifest's resource limits in CI.
+func assertCPUs(r *report, expected, tolerance float64) {
+	match := false
+	switch {
+	case r.CgroupEffective == nil:
+		match = expected == 0
+	case expected != 0:
+		// Allow for the rounding of limits to microsecond-quota precision.
+		match = math.Abs(*r.CgroupEffective-expected) <= tolerance+1e-9
+	}
+	r.AssertCPUs = &expected
+	r.AssertTolerance = &tolerance
+	r.AssertMatch = &match
+}
+
+// printAssert prints the result recorded by assertCPUs.
+func printAssert(w io.Writer, r report) {
+	actual := "unlimited"
+	if r.CgroupEffective != nil {
+		actual = formatCPUs(*r.CgroupEffective)
+	}
+	expected := "unlimited"
+	if *r.AssertCPUs != 0 {
+		expected = formatCPUs(*r.AssertCPUs)
+		if *r.AssertTolerance != 0 {
+			expected += " ± " + formatCPUs(*r.AssertTolerance)
+		}
+	}
+	if *r.AssertMatch {
+		fmt.Fprintf(w, "assert CPUs:             PASS effective %s, expected %s\n", actual, expected)
+	} else {
+		fmt.Fprintf(w, "assert CPUs:             FAIL effective %s, expected %s\n", actual, expected)
+	}
+}
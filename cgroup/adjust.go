@@ -0,0 +1,95 @@
+package cgroup
+
+import (
+	"fmt"
+	"math"
+)
+
+// defaultMinGOMAXPROCS is the smallest adjusted value the proposal allows.
+const defaultMinGOMAXPROCS = 2
+
+// Rounding is how a fractional CPU limit is converted to an integer
+// GOMAXPROCS.
+type Rounding int
+
+const (
+	// RoundCeil rounds up, so a 1.5 CPU limit becomes 2. This is the
+	// proposal's behavior.
+	RoundCeil Rounding = iota
+	// RoundFloor rounds down, so a 1.5 CPU limit becomes 1.
+	RoundFloor
+	// RoundNearest rounds to the nearest integer, with halves rounding up.
+	RoundNearest
+)
+
+func (r Rounding) String() string {
+	switch r {
+	case RoundCeil:
+		return "ceil"
+	case RoundFloor:
+		return "floor"
+	case RoundNearest:
+		return "round"
+	default:
+		return fmt.Sprintf("Rounding(%d)", int(r))
+	}
+}
+
+// ParseRounding parses the name of a Rounding: "ceil", "floor", or "round".
+func ParseRounding(s string) (Rounding, error) {
+	for _, r := range []Rounding{RoundCeil, RoundFloor, RoundNearest} {
+		if s == r.String() {
+			return r, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown rounding %q: must be ceil, floor, or round", s)
+}
+
+// Apply rounds limit to a whole number of CPUs, before any clamping.
+func (r Rounding) Apply(limit float64) float64 {
+	switch r {
+	case RoundFloor:
+		return math.Floor(limit)
+	case RoundNearest:
+		return math.Round(limit)
+	default:
+		return math.Ceil(limit)
+	}
+}
+
+// Adjust converts an effective CPU limit into a GOMAXPROCS value, or 0 if the
+// limit is 0. See AdjustedGOMAXPROCS. It returns an error if MinGOMAXPROCS
+// or MaxGOMAXPROCS is negative, or if MaxGOMAXPROCS is set below the
+// minimum.
+func (d *Detector) Adjust(limit float64) (int, error) {
+	minProcs := d.ResolvedMinGOMAXPROCS()
+	switch {
+	case d.MinGOMAXPROCS < 0:
+		return 0, fmt.Errorf("MinGOMAXPROCS %d is negative", d.MinGOMAXPROCS)
+	case d.MaxGOMAXPROCS < 0:
+		return 0, fmt.Errorf("MaxGOMAXPROCS %d is negative", d.MaxGOMAXPROCS)
+	case d.MaxGOMAXPROCS != 0 && d.MaxGOMAXPROCS < minProcs:
+		return 0, fmt.Errorf("MaxGOMAXPROCS %d is less than the minimum of %d", d.MaxGOMAXPROCS, minProcs)
+	}
+	if limit == 0 {
+		return 0, nil
+	}
+
+	// The adjusted CPU limit is the maximum of the minimum and the rounded
+	// effective limit. The proposal's minimum of 2 ensures some parallelism
+	// for GC and other background work.
+	procs := int(math.Max(float64(minProcs), d.Rounding.Apply(limit)))
+	if d.MaxGOMAXPROCS != 0 {
+		procs = min(procs, d.MaxGOMAXPROCS)
+	}
+	return procs, nil
+}
+
+// ResolvedMinGOMAXPROCS returns the minimum Adjust applies: MinGOMAXPROCS,
+// or the proposal's minimum of 2 if it is zero.
+func (d *Detector) ResolvedMinGOMAXPROCS() int {
+	if d.MinGOMAXPROCS == 0 {
+		return defaultMinGOMAXPROCS
+	}
+	return d.MinGOMAXPROCS
+}
@@ -0,0 +1,54 @@
+package cgroup
+
+import "testing"
+
+func TestAdjust(t *testing.T) {
+	cases := []struct {
+		name  string
+		d     Detector
+		limit float64
+		want  int
+	}{
+		{"unlimited", Detector{}, 0, 0},
+		{"default minimum", Detector{}, 0.5, 2},
+		{"ceil", Detector{}, 2.5, 3},
+		{"floor", Detector{Rounding: RoundFloor}, 2.5, 2},
+		{"round", Detector{Rounding: RoundNearest}, 3.4, 3},
+		{"min", Detector{MinGOMAXPROCS: 1}, 0.5, 1},
+		{"max", Detector{MaxGOMAXPROCS: 4}, 7.5, 4},
+		{"max equals min", Detector{MinGOMAXPROCS: 3, MaxGOMAXPROCS: 3}, 7.5, 3},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.d.Adjust(tc.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("Adjust(%g) = %d, want %d", tc.limit, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAdjustInvalid(t *testing.T) {
+	cases := []struct {
+		name string
+		d    Detector
+	}{
+		{"negative min", Detector{MinGOMAXPROCS: -1}},
+		{"negative max", Detector{MaxGOMAXPROCS: -1}},
+		{"max below min", Detector{MinGOMAXPROCS: 4, MaxGOMAXPROCS: 2}},
+		{"max below default min", Detector{MaxGOMAXPROCS: 1}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Even an unlimited cgroup reports the invalid configuration.
+			for _, limit := range []float64{0, 2.5} {
+				if got, err := tc.d.Adjust(limit); err == nil {
+					t.Errorf("Adjust(%g) = %d, want an error", limit, got)
+				}
+			}
+		})
+	}
+}
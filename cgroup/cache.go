@@ -0,0 +1,74 @@
+package cgroup
+
+import (
+	"sync"
+	"time"
+)
+
+// Cache memoizes the CPU limit of a Detector, only re-reading the cgroup
+// hierarchy once the TTL has elapsed. It is safe for concurrent use.
+type Cache struct {
+	d   *Detector
+	ttl time.Duration
+
+	mu     sync.Mutex
+	limit  CPULimit
+	err    error
+	readAt time.Time
+}
+
+// Cached returns a Cache of d's CPU limit that re-reads it at most once per
+// ttl.
+func (d *Detector) Cached(ttl time.Duration) *Cache {
+	return &Cache{d: d, ttl: ttl}
+}
+
+// Cached calls Cached on a Detector reading from the host.
+func Cached(ttl time.Duration) *Cache {
+	return (&Detector{}).Cached(ttl)
+}
+
+// CPU returns the cached CPU limit, re-reading it if the TTL has elapsed.
+// Errors are cached like limits.
+func (c *Cache) CPU() (CPULimit, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.readAt.IsZero() || time.Since(c.readAt) >= c.ttl {
+		c.refresh()
+	}
+	return c.limit, c.err
+}
+
+// EffectiveCPULimit returns the cached effective CPU limit. See
+// Detector.EffectiveCPULimit.
+func (c *Cache) EffectiveCPULimit() (float64, error) {
+	limit, err := c.CPU()
+	return limit.Effective, err
+}
+
+// AdjustedGOMAXPROCS returns the adjusted GOMAXPROCS of the cached CPU limit.
+// See Detector.AdjustedGOMAXPROCS.
+func (c *Cache) AdjustedGOMAXPROCS() (int, error) {
+	limit, err := c.CPU()
+	if err != nil {
+		return 0, err
+	}
+	return c.d.Adjust(limit.Effective)
+}
+
+// Refresh re-reads the CPU limit regardless of the TTL, e.g. after a known
+// resize, and returns it.
+func (c *Cache) Refresh() (CPULimit, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.refresh()
+	return c.limit, c.err
+}
+
+// refresh re-reads the CPU limit. c.mu must be held.
+func (c *Cache) refresh() {
+	c.limit, c.err = c.d.CPU()
+	c.readAt = time.Now()
+}
@@ -0,0 +1,162 @@
+package cgroup
+
+import (
+	"errors"
+	"io/fs"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"testing/synctest"
+	"time"
+)
+
+// countingFS counts the files opened through it, each of which is a syscall
+// on a real filesystem.
+type countingFS struct {
+	fs.FS
+	opens atomic.Int64
+}
+
+func (c *countingFS) Open(name string) (fs.File, error) {
+	c.opens.Add(1)
+	return c.FS.Open(name)
+}
+
+// cacheFS is a cgroup v2 tree limited to 2 CPUs by its cpu.max.
+func cacheFS() fstest.MapFS {
+	return fstest.MapFS{
+		"proc/self/cgroup":                 {Data: []byte("0::/kube\n")},
+		"sys/fs/cgroup/cgroup.controllers": {Data: []byte("cpu\n")},
+		"sys/fs/cgroup/kube/cpu.max":       {Data: []byte("200000 100000\n")},
+	}
+}
+
+// resize sets the quota of the tree returned by cacheFS.
+func resize(fsys fstest.MapFS, cpuMax string) {
+	fsys["sys/fs/cgroup/kube/cpu.max"] = &fstest.MapFile{Data: []byte(cpuMax + "\n")}
+}
+
+func TestCacheTTL(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		fsys := cacheFS()
+		counter := &countingFS{FS: fsys}
+		c := (&Detector{FS: counter}).Cached(time.Minute)
+
+		if limit, err := c.CPU(); err != nil || limit.Effective != 2 {
+			t.Fatalf("CPU() = %g, %v, want 2", limit.Effective, err)
+		}
+		opens := counter.opens.Load()
+
+		resize(fsys, "400000 100000")
+		time.Sleep(time.Minute - time.Nanosecond)
+		if limit, _ := c.CPU(); limit.Effective != 2 {
+			t.Errorf("CPU() before the TTL = %g, want the cached 2", limit.Effective)
+		}
+		if n := counter.opens.Load(); n != opens {
+			t.Errorf("CPU() before the TTL opened %d files, want 0", n-opens)
+		}
+
+		time.Sleep(time.Nanosecond)
+		if limit, _ := c.CPU(); limit.Effective != 4 {
+			t.Errorf("CPU() after the TTL = %g, want 4", limit.Effective)
+		}
+	})
+}
+
+func TestCacheRefresh(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		fsys := cacheFS()
+		c := (&Detector{FS: fsys}).Cached(time.Hour)
+		if _, err := c.CPU(); err != nil {
+			t.Fatal(err)
+		}
+
+		resize(fsys, "400000 100000")
+		if limit, err := c.Refresh(); err != nil || limit.Effective != 4 {
+			t.Errorf("Refresh() = %g, %v, want 4", limit.Effective, err)
+		}
+		// The refreshed limit is cached for a new TTL.
+		resize(fsys, "100000 100000")
+		if limit, _ := c.CPU(); limit.Effective != 4 {
+			t.Errorf("CPU() after Refresh = %g, want 4", limit.Effective)
+		}
+	})
+}
+
+func TestCacheError(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		fsys := fstest.MapFS{}
+		counter := &countingFS{FS: fsys}
+		c := (&Detector{FS: counter}).Cached(time.Minute)
+
+		if _, err := c.CPU(); !errors.Is(err, ErrNotInCgroup) {
+			t.Fatalf("CPU() = %v, want ErrNotInCgroup", err)
+		}
+		opens := counter.opens.Load()
+
+		// The error is cached until the TTL elapses, even once it is fixed.
+		for name, f := range cacheFS() {
+			fsys[name] = f
+		}
+		if _, err := c.CPU(); !errors.Is(err, ErrNotInCgroup) {
+			t.Errorf("CPU() before the TTL = %v, want the cached ErrNotInCgroup", err)
+		}
+		if n := counter.opens.Load(); n != opens {
+			t.Errorf("CPU() before the TTL opened %d files, want 0", n-opens)
+		}
+
+		time.Sleep(time.Minute)
+		if limit, err := c.CPU(); err != nil || limit.Effective != 2 {
+			t.Errorf("CPU() after the TTL = %g, %v, want 2", limit.Effective, err)
+		}
+	})
+}
+
+// TestCacheConcurrent is meant to be run with -race.
+func TestCacheConcurrent(t *testing.T) {
+	for _, ttl := range []time.Duration{0, time.Hour} {
+		c := (&Detector{FS: cacheFS()}).Cached(ttl)
+		var wg sync.WaitGroup
+		for range 8 {
+			wg.Go(func() {
+				for range 100 {
+					if limit, err := c.CPU(); err != nil || limit.Effective != 2 {
+						t.Errorf("CPU() = %g, %v, want 2", limit.Effective, err)
+						return
+					}
+				}
+			})
+		}
+		wg.Go(func() { c.Refresh() })
+		wg.Wait()
+	}
+}
+
+func BenchmarkCacheCPU(b *testing.B) {
+	counter := &countingFS{FS: cacheFS()}
+	c := (&Detector{FS: counter}).Cached(time.Hour)
+	if _, err := c.CPU(); err != nil {
+		b.Fatal(err)
+	}
+	counter.opens.Store(0)
+
+	b.ReportAllocs()
+	for b.Loop() {
+		c.CPU()
+	}
+	b.ReportMetric(float64(counter.opens.Load())/float64(b.N), "opens/op")
+}
+
+func BenchmarkDetectorCPU(b *testing.B) {
+	counter := &countingFS{FS: cacheFS()}
+	d := &Detector{FS: counter}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := d.CPU(); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(counter.opens.Load())/float64(b.N), "opens/op")
+}
@@ -0,0 +1,553 @@
+// Package cgroup detects the CPU limit imposed on the current process by
+// Linux control groups, following the container-aware GOMAXPROCS proposal:
+// https://github.com/golang/go/issues/73193#user-content-proposal
+//
+// On Windows the CPU limit is read from the job object's CPU rate control.
+package cgroup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"math"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+)
+
+// Paths are relative to the root of a Detector's filesystem.
+const (
+	// cgroupV2Path is the default cgroup v2 root path. Cgroup v1
+	// controllers default to subdirectories of it, e.g. "sys/fs/cgroup/cpu".
+	cgroupV2Path = "sys/fs/cgroup"
+	// procSelfCgroupPath lists the cgroups of the current process
+	procSelfCgroupPath = "proc/self/cgroup"
+	// cgroupV1UnlimitedQuota is the unlimited quota value for cgroup v1
+	cgroupV1UnlimitedQuota = -1
+)
+
+// hostFS is the host's root filesystem.
+var hostFS = os.DirFS("/")
+
+// Detector reads cgroup limits from a filesystem. The zero value reads from
+// the host.
+type Detector struct {
+	// FS is the filesystem containing /sys and /proc. Paths are relative to
+	// its root, e.g. "sys/fs/cgroup/cpu.max". If nil the host's root
+	// filesystem is used.
+	FS fs.FS
+
+	// MinGOMAXPROCS is the smallest adjusted GOMAXPROCS. If zero the
+	// proposal's minimum of 2 is used.
+	MinGOMAXPROCS int
+
+	// MaxGOMAXPROCS, if not zero, is the largest adjusted GOMAXPROCS, e.g.
+	// for programs whose lock contention outweighs more parallelism. It must
+	// be at least MinGOMAXPROCS.
+	MaxGOMAXPROCS int
+
+	// Rounding converts a fractional CPU limit to an integer GOMAXPROCS.
+	// The zero value rounds up, as the proposal does.
+	Rounding Rounding
+
+	// PID is the process whose limits are read, via /proc/<pid>/cgroup. If
+	// zero the current process is used. Cgroup mounts are always found via
+	// /proc/self/mountinfo, so the process must share the caller's cgroup
+	// namespace.
+	PID int
+
+	// CgroupV2Path is where the cgroup v2 hierarchy is mounted, relative to
+	// the root of FS, e.g. "sys/fs/cgroup". If empty it is found via
+	// /proc/self/mountinfo, falling back to "sys/fs/cgroup".
+	CgroupV2Path string
+
+	// CgroupV1Path is the directory containing a cgroup v1 hierarchy per
+	// controller, e.g. "sys/fs/cgroup" for "sys/fs/cgroup/cpu". If empty the
+	// hierarchies are found via /proc/self/mountinfo, falling back to
+	// "sys/fs/cgroup".
+	CgroupV1Path string
+
+	// LeafOnly reads limits only from the process's own cgroup rather than
+	// taking the minimum across its ancestors, e.g. to tell whether a
+	// container sets a quota itself or inherits one from a parent slice.
+	LeafOnly bool
+
+	// Nearest stops walking up from the process's cgroup at the first level
+	// that sets a limit, rather than taking the minimum across all levels.
+	// An ancestor with a lower limit still caps the process, so this only
+	// matches the kernel when the nearest limit is the binding one, as
+	// orchestrators that set quotas at the pod level arrange. LeafOnly takes
+	// precedence.
+	Nearest bool
+
+	// Reread reads each CPU quota twice and fails with ErrTornRead if the
+	// reads differ, to catch files read while a live resize rewrites them.
+	// It is off by default to avoid the extra reads.
+	Reread bool
+
+	// CPULimitEnv, if not empty, names an environment variable holding the
+	// CPU limit in CPUs, e.g. "MESOS_CPU_LIMIT", for platforms that inject
+	// the limit directly. It is used only when the cgroup limit cannot be
+	// read or is unlimited, and is read from the calling process's
+	// environment even if PID is set. See EnvCPULimit.
+	CPULimitEnv string
+
+	// Visit, if not nil, is called for each cgroup directory whose CPU
+	// quota is read, from the process's cgroup up, with the quota/period
+	// ratio set there (0 if none) and any error reading it, e.g. to export
+	// each level to a tracing system. Missing quota files are not errors.
+	// dir is relative to the root of FS, as in Level.
+	Visit func(dir string, limit float64, err error)
+
+	// Logger, if not nil, receives the result of each CPU limit detection
+	// as structured attributes.
+	Logger *slog.Logger
+}
+
+// procCgroupPath returns the path listing the cgroups of process pid, or of
+// the current process if pid is 0.
+func procCgroupPath(pid int) string {
+	if pid == 0 {
+		return procSelfCgroupPath
+	}
+	return path.Join("proc", strconv.Itoa(pid), "cgroup")
+}
+
+// checkProcess returns ErrNoProcess if process pid has exited, since limits
+// read while it was exiting may be incomplete. A pid of 0 is always running.
+func checkProcess(fsys fs.FS, pid int) error {
+	if pid == 0 {
+		return nil
+	}
+	if _, err := fs.Stat(fsys, path.Join("proc", strconv.Itoa(pid))); errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: pid %d exited while reading its cgroup", ErrNoProcess, pid)
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// fsys returns the filesystem to read from, failing reads once ctx is done.
+func (d *Detector) fsys(ctx context.Context) fs.FS {
+	fsys := d.FS
+	if fsys == nil {
+		fsys = hostFS
+	}
+	if ctx.Done() == nil {
+		// The context can never be canceled.
+		return fsys
+	}
+	return ctxFS{FS: fsys, ctx: ctx}
+}
+
+// resolve finds the mounted cgroup hierarchies and parses the process's
+// cgroups, which every limit is read relative to, so that several limits
+// can be read from a single parse. The filesystem and mounts are returned
+// even on error.
+func (d *Detector) resolve(ctx context.Context) (fs.FS, mounts, procCgroups, error) {
+	fsys := d.fsys(ctx)
+
+	m := d.findMounts(fsys)
+	if err := ctx.Err(); err != nil {
+		// Mount discovery fails quietly, so don't mistake cancellation for
+		// not being in a cgroup.
+		return fsys, m, procCgroups{}, err
+	}
+	cgroups, err := parseProcessCgroups(fsys, d.PID)
+	if err != nil {
+		return fsys, m, procCgroups{}, err
+	}
+	return fsys, m, cgroups, nil
+}
+
+// CPULimit describes the CPU limit imposed on a process. Limits are in CPUs
+// and are 0 if the process's cgroup does not limit CPU.
+type CPULimit struct {
+	// Effective is the steady-state limit: the minimum quota/period ratio
+	// found walking from the process's cgroup up to the cgroup root, further
+	// capped by the number of CPUs in the process's cpuset.
+	Effective float64
+
+	// Unlimited is true if the process is in a cgroup but no level limits
+	// CPU, either because every quota is "max" or -1 or because none is set.
+	// It distinguishes an unrestricted container from ErrNotInCgroup.
+	Unlimited bool
+
+	// Burst is like Effective but adds any burst budget to each quota, i.e.
+	// (quota + burst) / period. It is the most CPU the process can use in a
+	// single period and equals Effective when no burst is configured.
+	Burst float64
+
+	// Quota and Period are the raw CFS values, in microseconds, of the
+	// cgroup that set Effective. They are 0 if Effective is 0 or was set by
+	// a cpuset.
+	Quota, Period int64
+
+	// LimitedBy is the cgroup directory that set Effective, relative to the
+	// root of the Detector's filesystem. It is empty if Effective is 0.
+	LimitedBy string
+
+	// Leaf is the quota/period ratio of the process's own cgroup, ignoring
+	// ancestors and cpusets. Tools like go.uber.org/automaxprocs only read
+	// this level.
+	Leaf float64
+
+	// Hierarchy is the minimum quota/period ratio from the process's cgroup
+	// up to the root, ignoring LeafOnly, Nearest, and cpusets. The Go 1.25
+	// runtime derives its default GOMAXPROCS from this limit.
+	Hierarchy float64
+
+	// Weight is the CPU weight of the process's own cgroup relative to the
+	// default: cpu.shares/1024 in v1 or cpu.weight/100 in v2. Weights only
+	// divide CPU time under contention, so it is not a limit and does not
+	// affect Effective. It is 0 if no weight can be read.
+	Weight float64
+
+	// Unreadable lists the cgroup directories whose quota or cpuset could not
+	// be read for lack of permission, e.g. under AppArmor or SELinux. If it
+	// is not empty Effective may miss a limit.
+	Unreadable []string
+
+	// ReadErrors holds errors reading quota files that exist, other than
+	// permission errors, such as malformed contents. The levels they occur
+	// at are skipped, so if it is not empty Effective may also miss a limit.
+	// Missing files are not errors since most levels set no limit.
+	ReadErrors []error
+
+	// Levels lists each cgroup directory whose quota was read to find
+	// Effective, from the process's cgroup up to the root of each
+	// hierarchy.
+	Levels []Level
+
+	// FromEnv is the Detector's CPULimitEnv if Effective and Burst were
+	// taken from it rather than from cgroups, and is otherwise empty.
+	FromEnv string
+
+	// CgroupErr is the error reading the cgroup limit that FromEnv
+	// replaced, or nil if the cgroup was read but does not limit CPU.
+	CgroupErr error
+}
+
+// BurstRatio returns the burst budget as a fraction of the period, i.e.
+// Burst - Effective, or 0 if no burst is configured. A ratio of 1 lets the
+// cgroup use a whole extra period's worth of quota in a single period.
+func (l CPULimit) BurstRatio() float64 {
+	if l.Burst <= l.Effective {
+		return 0
+	}
+	// Round away the error of subtracting two ratios, to microsecond
+	// precision.
+	return math.Round((l.Burst-l.Effective)*1e6) / 1e6
+}
+
+// Level is the CPU quota set at one cgroup directory.
+type Level struct {
+	// Dir is relative to the root of the Detector's filesystem.
+	Dir string
+	// Limit is the quota/period ratio set at Dir, or 0 if none is set.
+	Limit float64
+	// Quota and Period are the raw CFS values at Dir in microseconds. Quota
+	// is -1 if it is unlimited, and both are 0 if they cannot be read.
+	Quota, Period int64
+}
+
+// CPU returns the CPU limit of the current process, or of PID if set. It
+// returns ErrNotInCgroup if the process is not in a cgroup, and ErrNoProcess
+// if PID does not exist or exits while its limits are read.
+//
+// On Windows, when FS and PID are zero, the CPU rate control of the process's job
+// object is used instead of cgroups. On other platforms besides Linux it
+// returns ErrPlatformUnsupported when FS and PID are zero.
+func (d *Detector) CPU() (CPULimit, error) {
+	return d.CPUContext(context.Background())
+}
+
+// CPUContext is like CPU but stops reading cgroup files and returns
+// ctx.Err() once ctx is done.
+func (d *Detector) CPUContext(ctx context.Context) (CPULimit, error) {
+	fsys, m, cgroups, err := d.resolve(ctx)
+	return d.cpuContext(ctx, fsys, m, cgroups, err)
+}
+
+// cpuContext implements CPUContext given what resolve returned, including
+// its error, since CPULimitEnv may stand in for an unreadable cgroup.
+func (d *Detector) cpuContext(ctx context.Context, fsys fs.FS, m mounts, cgroups procCgroups, err error) (CPULimit, error) {
+	var limit CPULimit
+	if platformLimit, ok, platformErr := d.platformCPULimit(); ok {
+		limit, err = platformLimit, platformErr
+	} else if err == nil {
+		limit, err = d.cpu(fsys, cgroups, m)
+	}
+	if d.CPULimitEnv != "" {
+		limit, err = envFallback(limit, err, d.CPULimitEnv)
+	}
+	d.logCPU(ctx, limit, err)
+	return limit, err
+}
+
+// platformCPULimit returns the CPU limit the platform imposes other than
+// through cgroups, if it has such a mechanism and the Detector reads from
+// the host.
+func (d *Detector) platformCPULimit() (CPULimit, bool, error) {
+	if d.FS != nil || d.PID != 0 {
+		return CPULimit{}, false, nil
+	}
+	return platformCPULimit()
+}
+
+// envFallback replaces an unlimited or unreadable cgroup CPU limit with the
+// limit in the environment variable name, if it is set and valid.
+func envFallback(limit CPULimit, err error, name string) (CPULimit, error) {
+	if err == nil && !limit.Unlimited {
+		return limit, nil
+	}
+	if errors.Is(err, ErrNoProcess) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		// The environment says nothing about another process that exited,
+		// and the caller gave up.
+		return limit, err
+	}
+	v, ok, envErr := EnvCPULimit(name)
+	if !ok || envErr != nil {
+		return limit, err
+	}
+	if err != nil {
+		return CPULimit{Effective: v, Burst: v, FromEnv: name, CgroupErr: err}, nil
+	}
+	limit.Effective, limit.Burst, limit.Unlimited = v, v, false
+	limit.FromEnv = name
+	return limit, nil
+}
+
+// cpu reads the cgroup CPU limit of the process whose cgroups are listed in
+// cgroups.
+func (d *Detector) cpu(fsys fs.FS, cgroups procCgroups, m mounts) (CPULimit, error) {
+	hs := m.hierarchiesFor(fsys, "cpu")
+	if len(hs) == 0 {
+		return CPULimit{}, ErrNotInCgroup
+	}
+
+	var levels []Level
+	record := func(readQuota func(fs.FS, string) (cpuQuota, error)) func(fs.FS, string) (float64, error) {
+		return func(fsys fs.FS, dir string) (float64, error) {
+			q, err := readQuota(fsys, dir)
+			if err != nil {
+				levels = append(levels, Level{Dir: dir})
+				d.visit(dir, 0, err)
+				return 0, err
+			}
+			level := Level{Dir: dir, Quota: q.quota, Period: q.period}
+			if limit := q.limit(); !math.IsInf(limit, 1) {
+				level.Limit = limit
+			}
+			levels = append(levels, level)
+			d.visit(dir, level.Limit, nil)
+			return q.limit(), nil
+		}
+	}
+	readV1, readV2 := readV1CPUQuota, readV2CPUQuota
+	if d.Reread {
+		readV1, readV2 = rereadCPUQuota(readV1), rereadCPUQuota(readV2)
+	}
+	quota, err := getMinLimit(fsys, cgroups, hs, "cpu", d.walkMode(), record(readV1), record(readV2))
+	if err != nil {
+		return CPULimit{}, err
+	}
+	burst, err := getMinLimit(fsys, cgroups, hs, "cpu", d.walkMode(), calculateV1CPUBurst, calculateV2CPUBurst)
+	if err != nil {
+		return CPULimit{}, err
+	}
+	hierarchy := quota
+	if d.walkMode() != walkAll {
+		hierarchy, err = getMinLimit(fsys, cgroups, hs, "cpu", walkAll, calculateV1CPUQuota, calculateV2CPUQuota)
+		if err != nil {
+			return CPULimit{}, err
+		}
+	}
+	leaf, err := getMinLimit(fsys, cgroups, hs, "cpu", walkLeaf, calculateV1CPUQuota, calculateV2CPUQuota)
+	if err != nil {
+		return CPULimit{}, err
+	}
+	weight, err := getMinLimit(fsys, cgroups, hs, "cpu", walkLeaf, calculateV1CPUShares, calculateV2CPUWeight)
+	if err != nil {
+		return CPULimit{}, err
+	}
+
+	cpus, err := getCpusetLimit(fsys, cgroups, m)
+	if err != nil {
+		return CPULimit{}, err
+	}
+
+	if err := contextErr(fsys); err != nil {
+		return CPULimit{}, err
+	}
+	if err := checkProcess(fsys, d.PID); err != nil {
+		return CPULimit{}, err
+	}
+
+	effective := minLimitAt(quota, cpus)
+	var raw Level
+	for _, l := range levels {
+		if l.Dir == effective.path && l.Limit == effective.limit {
+			raw = l
+			break
+		}
+	}
+	return CPULimit{
+		Effective:  effective.limit,
+		Unlimited:  effective.limit == 0,
+		Quota:      raw.Quota,
+		Period:     raw.Period,
+		Burst:      minLimitAt(burst, cpus).limit,
+		LimitedBy:  effective.path,
+		Leaf:       leaf.limit,
+		Hierarchy:  hierarchy.limit,
+		Weight:     weight.limit,
+		Levels:     levels,
+		Unreadable: uniqueDirs(append(quota.denied, cpus.denied...)),
+		ReadErrors: quota.failed,
+	}, nil
+}
+
+// visit calls d.Visit, if set, for a level of the CPU quota walk.
+func (d *Detector) visit(dir string, limit float64, err error) {
+	if d.Visit == nil {
+		return
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		err = nil
+	}
+	d.Visit(dir, limit, err)
+}
+
+// uniqueDirs returns dirs without duplicates, in their original order.
+func uniqueDirs(dirs []string) []string {
+	var unique []string
+	for _, dir := range dirs {
+		if !slices.Contains(unique, dir) {
+			unique = append(unique, dir)
+		}
+	}
+	return unique
+}
+
+// CPUFiles returns the cgroup files CPU limits are read from, at every level
+// of the hierarchy, for example to watch them for changes. Only files that
+// exist are returned. Paths are relative to the root of the Detector's
+// filesystem.
+func (d *Detector) CPUFiles() ([]string, error) {
+	fsys, m, cgroups, err := d.resolve(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	hs := m.hierarchiesFor(fsys, "cpu")
+	if len(hs) == 0 {
+		return nil, ErrNotInCgroup
+	}
+
+	var files []string
+	for _, h := range hs {
+		names := []string{"cpu.cfs_quota_us", "cpu.cfs_period_us", "cpu.cfs_burst_us"}
+		if h.v2 {
+			names = []string{"cpu.max", "cpu.max.burst"}
+		}
+
+		// Reuse the walk to visit each level, collecting files rather than
+		// calculating limits.
+		collectFiles := func(fsys fs.FS, dir string) (float64, error) {
+			for _, name := range names {
+				file := path.Join(dir, name)
+				if _, err := fs.Stat(fsys, file); err == nil {
+					files = append(files, file)
+				}
+			}
+			return math.Inf(1), nil
+		}
+		_, err := getCgroupLimit(fsys, cgroups, h, "cpu", d.walkMode(), collectFiles)
+		if errors.Is(err, ErrCgroupUnsupported) && len(hs) > 1 {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// EffectiveCPULimit returns the steady-state CPU limit of the current
+// process. It returns 0 if the process's cgroup does not limit CPU, and
+// ErrNotInCgroup if the process is not in a cgroup. See CPULimit.
+func (d *Detector) EffectiveCPULimit() (float64, error) {
+	return d.EffectiveCPULimitContext(context.Background())
+}
+
+// EffectiveCPULimitContext is like EffectiveCPULimit but stops reading cgroup
+// files and returns ctx.Err() once ctx is done.
+func (d *Detector) EffectiveCPULimitContext(ctx context.Context) (float64, error) {
+	limit, err := d.CPUContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return limit.Effective, nil
+}
+
+// AdjustedGOMAXPROCS returns the GOMAXPROCS value the proposal derives from
+// the effective CPU limit: the ceiling of the limit (or as specified by
+// Rounding) with a minimum of 2 (or MinGOMAXPROCS) and at most
+// MaxGOMAXPROCS, if set. It returns 0 if the process's cgroup does not limit
+// CPU, ErrNotInCgroup if the process is not in a cgroup, and an error if
+// MinGOMAXPROCS and MaxGOMAXPROCS are invalid. See Adjust.
+func (d *Detector) AdjustedGOMAXPROCS() (int, error) {
+	return d.AdjustedGOMAXPROCSContext(context.Background())
+}
+
+// AdjustedGOMAXPROCSContext is like AdjustedGOMAXPROCS but stops reading
+// cgroup files and returns ctx.Err() once ctx is done.
+func (d *Detector) AdjustedGOMAXPROCSContext(ctx context.Context) (int, error) {
+	limit, err := d.EffectiveCPULimitContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return d.Adjust(limit)
+}
+
+// CPU calls CPU on a Detector reading from the host.
+func CPU() (CPULimit, error) {
+	return (&Detector{}).CPU()
+}
+
+// CPUContext calls CPUContext on a Detector reading from the host.
+func CPUContext(ctx context.Context) (CPULimit, error) {
+	return (&Detector{}).CPUContext(ctx)
+}
+
+// CPUFiles calls CPUFiles on a Detector reading from the host.
+func CPUFiles() ([]string, error) {
+	return (&Detector{}).CPUFiles()
+}
+
+// EffectiveCPULimit calls EffectiveCPULimit on a Detector reading from the
+// host.
+func EffectiveCPULimit() (float64, error) {
+	return (&Detector{}).EffectiveCPULimit()
+}
+
+// EffectiveCPULimitContext calls EffectiveCPULimitContext on a Detector
+// reading from the host.
+func EffectiveCPULimitContext(ctx context.Context) (float64, error) {
+	return (&Detector{}).EffectiveCPULimitContext(ctx)
+}
+
+// AdjustedGOMAXPROCS calls AdjustedGOMAXPROCS on a Detector reading from the
+// host.
+func AdjustedGOMAXPROCS() (int, error) {
+	return (&Detector{}).AdjustedGOMAXPROCS()
+}
+
+// AdjustedGOMAXPROCSContext calls AdjustedGOMAXPROCSContext on a Detector
+// reading from the host.
+func AdjustedGOMAXPROCSContext(ctx context.Context) (int, error) {
+	return (&Detector{}).AdjustedGOMAXPROCSContext(ctx)
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !windows
+
+package cgroup
+
+// platformCPULimit returns ErrPlatformUnsupported: there are no cgroups
+// outside Linux.
+func platformCPULimit() (limit CPULimit, ok bool, err error) {
+	return CPULimit{}, true, ErrPlatformUnsupported
+}
@@ -0,0 +1,142 @@
+package cgroup
+
+import (
+	"io/fs"
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+// hybridMountinfo mounts the unified hierarchy alongside a v1 cpu
+// hierarchy, as systemd's hybrid layout does.
+const hybridMountinfo = `30 25 0:26 / /sys/fs/cgroup/unified rw,nosuid - cgroup2 cgroup2 rw
+31 25 0:27 / /sys/fs/cgroup/cpu,cpuacct rw,nosuid - cgroup cgroup rw,cpu,cpuacct
+`
+
+func TestCPUHybrid(t *testing.T) {
+	const (
+		v1Dir = "sys/fs/cgroup/cpu,cpuacct/app"
+		v2Dir = "sys/fs/cgroup/unified/app"
+	)
+	cases := []struct {
+		name          string
+		v1Quota       string
+		v2Max         string
+		want          float64
+		wantLimitedBy string
+	}{
+		{"only v1", "150000", "", 1.5, v1Dir},
+		{"v1 lower", "150000", "300000 100000", 1.5, v1Dir},
+		{"v2 lower", "150000", "50000 100000", 0.5, v2Dir},
+		{"only v2", "-1", "50000 100000", 0.5, v2Dir},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			fsys := fstest.MapFS{
+				"proc/self/mountinfo":        {Data: []byte(hybridMountinfo)},
+				"proc/self/cgroup":           {Data: []byte("4:cpu,cpuacct:/app\n0::/app\n")},
+				v1Dir + "/cpu.cfs_quota_us":  {Data: []byte(tc.v1Quota + "\n")},
+				v1Dir + "/cpu.cfs_period_us": {Data: []byte("100000\n")},
+			}
+			if tc.v2Max != "" {
+				fsys[v2Dir+"/cpu.max"] = &fstest.MapFile{Data: []byte(tc.v2Max + "\n")}
+			}
+
+			d := &Detector{FS: fsys}
+			limit, err := d.CPU()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if limit.Effective != tc.want || limit.LimitedBy != tc.wantLimitedBy {
+				t.Errorf("CPU() = %g limited by %q, want %g limited by %q", limit.Effective, limit.LimitedBy, tc.want, tc.wantLimitedBy)
+			}
+			if v := d.CgroupVersion(); v != "hybrid" {
+				t.Errorf("CgroupVersion() = %q, want hybrid", v)
+			}
+		})
+	}
+}
+
+// deniedFS fails to open the files in denied with fs.ErrPermission, as
+// AppArmor and SELinux do on hardened hosts.
+type deniedFS struct {
+	fs.FS
+	denied []string
+}
+
+func (d deniedFS) Open(name string) (fs.File, error) {
+	if slices.Contains(d.denied, name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+	}
+	return d.FS.Open(name)
+}
+
+// nestedV2FS is a cgroup v2 tree for a process in /pod/app, limited to 1.5
+// CPUs by /pod. The root has no cpu.max, as on every v2 host.
+func nestedV2FS() fstest.MapFS {
+	return fstest.MapFS{
+		"proc/self/cgroup":                 {Data: []byte("0::/pod/app\n")},
+		"sys/fs/cgroup/cgroup.controllers": {Data: []byte("cpu\n")},
+		"sys/fs/cgroup/pod/cpu.max":        {Data: []byte("150000 100000\n")},
+		"sys/fs/cgroup/pod/app/cpu.max":    {Data: []byte("max 100000\n")},
+	}
+}
+
+func TestCPUPermissionDenied(t *testing.T) {
+	cases := []struct {
+		name           string
+		denied         []string
+		want           float64
+		wantUnreadable []string
+	}{
+		{"readable", nil, 1.5, nil},
+		{"leaf denied", []string{"sys/fs/cgroup/pod/app/cpu.max"}, 1.5, []string{"sys/fs/cgroup/pod/app"}},
+		{"limit denied", []string{"sys/fs/cgroup/pod/cpu.max"}, 0, []string{"sys/fs/cgroup/pod"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := &Detector{FS: deniedFS{FS: nestedV2FS(), denied: tc.denied}}
+			limit, err := d.CPU()
+			if err != nil {
+				// In particular, not ErrNotInCgroup.
+				t.Fatal(err)
+			}
+			if limit.Effective != tc.want {
+				t.Errorf("Effective = %g, want %g", limit.Effective, tc.want)
+			}
+			// The missing root cpu.max is not unreadable.
+			if !slices.Equal(limit.Unreadable, tc.wantUnreadable) {
+				t.Errorf("Unreadable = %q, want %q", limit.Unreadable, tc.wantUnreadable)
+			}
+		})
+	}
+}
+
+func TestCPUHierarchy(t *testing.T) {
+	cases := []struct {
+		name          string
+		d             Detector
+		wantEffective float64
+	}{
+		{"all", Detector{}, 1.5},
+		{"leaf only", Detector{LeafOnly: true}, 3},
+		{"nearest", Detector{Nearest: true}, 3},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			fsys := nestedV2FS()
+			fsys["sys/fs/cgroup/pod/app/cpu.max"] = &fstest.MapFile{Data: []byte("300000 100000\n")}
+			tc.d.FS = fsys
+			limit, err := tc.d.CPU()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if limit.Effective != tc.wantEffective {
+				t.Errorf("Effective = %g, want %g", limit.Effective, tc.wantEffective)
+			}
+			if limit.Hierarchy != 1.5 {
+				t.Errorf("Hierarchy = %g, want 1.5", limit.Hierarchy)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+//go:build windows
+
+package cgroup
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// Flags for JOBOBJECT_CPU_RATE_CONTROL_INFORMATION.ControlFlags.
+const (
+	jobObjectCPURateControlEnable     = 0x1
+	jobObjectCPURateControlWeightBase = 0x2
+	jobObjectCPURateControlHardCap    = 0x4
+	jobObjectCPURateControlMinMaxRate = 0x10
+)
+
+// jobObjectCPURateControlInformation mirrors
+// JOBOBJECT_CPU_RATE_CONTROL_INFORMATION. Rate is a union of CpuRate, Weight,
+// and the MinRate/MaxRate pair.
+type jobObjectCPURateControlInformation struct {
+	ControlFlags uint32
+	Rate         uint32
+}
+
+// platformCPULimit derives the CPU limit from the CPU rate control of the
+// job object the process is assigned to, as Windows containers are. ok is
+// false if the process is not in a job.
+func platformCPULimit() (limit CPULimit, ok bool, err error) {
+	var info jobObjectCPURateControlInformation
+	// A nil handle queries the job the calling process is assigned to, and
+	// fails if there is none.
+	err = windows.QueryInformationJobObject(0, windows.JobObjectCpuRateControlInformation,
+		uintptr(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info)), nil)
+	if err != nil {
+		return CPULimit{}, false, nil
+	}
+
+	// Weight based rates are relative, like cgroup cpu.weight, and do not
+	// cap CPU usage.
+	if info.ControlFlags&jobObjectCPURateControlEnable == 0 || info.ControlFlags&jobObjectCPURateControlWeightBase != 0 {
+		return CPULimit{Unlimited: true}, true, nil
+	}
+
+	// Rates are in hundredths of a percent of all processors in the system,
+	// so 10000 is every CPU.
+	rate := info.Rate
+	if info.ControlFlags&jobObjectCPURateControlMinMaxRate != 0 {
+		rate = info.Rate >> 16 // MaxRate
+	} else if info.ControlFlags&jobObjectCPURateControlHardCap == 0 {
+		return CPULimit{Unlimited: true}, true, nil
+	}
+
+	cpus := float64(windows.GetActiveProcessorCount(windows.ALL_PROCESSOR_GROUPS))
+	effective := cpus * float64(rate) / 10000
+	return CPULimit{Effective: effective, Burst: effective}, true, nil
+}
@@ -0,0 +1,65 @@
+package cgroup
+
+import (
+	"context"
+	"io/fs"
+	"strings"
+)
+
+// runtimeMarkers maps substrings of cgroup path components to the container
+// runtime or orchestrator that names its cgroups that way.
+var runtimeMarkers = []struct {
+	marker, name string
+}{
+	{"kubepods", "kubepods"},
+	{"cri-containerd", "containerd"},
+	{"containerd", "containerd"},
+	{"crio", "cri-o"},
+	{"libpod", "podman"},
+	{"docker", "docker"},
+	{"lxc", "lxc"},
+}
+
+// ContainerRuntimes guesses the container runtimes and orchestrators that
+// created the cgroup at cgroupPath from the names of its components, such as
+// ["containerd", "kubepods"] for
+// "/kubepods.slice/kubepods-pod1.slice/cri-containerd-abc.scope". The
+// innermost layer is first. It is a best-effort heuristic.
+func ContainerRuntimes(cgroupPath string) []string {
+	var names []string
+	components := strings.Split(cgroupPath, "/")
+	for i := len(components) - 1; i >= 0; i-- {
+		for _, m := range runtimeMarkers {
+			if !strings.Contains(components[i], m.marker) {
+				continue
+			}
+			// Runtimes usually nest several cgroups of their own.
+			if len(names) == 0 || names[len(names)-1] != m.name {
+				names = append(names, m.name)
+			}
+			break
+		}
+	}
+	return names
+}
+
+// CgroupPath returns the cgroup of the current process, or of PID if set,
+// as listed in /proc/self/cgroup. On hybrid hosts the path in the cgroup v1
+// cpu hierarchy is preferred since the cpu controller is attached there.
+func (d *Detector) CgroupPath() (string, error) {
+	fsys, m, cgroups, err := d.resolve(context.Background())
+	if err != nil {
+		return "", err
+	}
+	return cgroupPath(fsys, cgroups, m)
+}
+
+// cgroupPath implements CgroupPath for the process whose cgroups are listed
+// in cgroups.
+func cgroupPath(fsys fs.FS, cgroups procCgroups, m mounts) (string, error) {
+	controller := ""
+	if _, ok := m.cgroupV1(fsys, "cpu"); ok {
+		controller = "cpu"
+	}
+	return cgroups.path(controller)
+}
@@ -0,0 +1,46 @@
+package cgroup
+
+import (
+	"context"
+	"io/fs"
+)
+
+// ctxFS fails file operations once its context is done, bounding how long
+// detection spends on slow sysfs reads.
+type ctxFS struct {
+	fs.FS
+	ctx context.Context
+}
+
+func (c ctxFS) Open(name string) (fs.File, error) {
+	if err := c.ctx.Err(); err != nil {
+		return nil, err
+	}
+	f, err := c.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return ctxFile{File: f, ctx: c.ctx}, nil
+}
+
+// ctxFile fails reads once its context is done.
+type ctxFile struct {
+	fs.File
+	ctx context.Context
+}
+
+func (c ctxFile) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.File.Read(p)
+}
+
+// contextErr returns the error of fsys's context, if it has one and it is
+// done.
+func contextErr(fsys fs.FS) error {
+	if c, ok := fsys.(ctxFS); ok {
+		return c.ctx.Err()
+	}
+	return nil
+}
@@ -0,0 +1,307 @@
+package cgroup
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const (
+	// cgroupV1DefaultShares is the cpu.shares of a cgroup v1 cgroup that
+	// has not been given a weight.
+	cgroupV1DefaultShares = 1024
+	// cgroupV2DefaultWeight is the cpu.weight of a cgroup v2 cgroup that
+	// has not been given a weight.
+	cgroupV2DefaultWeight = 100
+	// cgroupV2DefaultPeriod is the cpu.max period, in microseconds, when
+	// none is given.
+	cgroupV2DefaultPeriod = 100000
+)
+
+// cpuQuota is a raw CFS quota and period in microseconds.
+type cpuQuota struct {
+	// quota is -1 if unlimited.
+	quota  int64
+	period int64
+}
+
+// limit returns the quota/period ratio, or +Inf if the quota is unlimited.
+func (q cpuQuota) limit() float64 {
+	if q.quota < 0 {
+		return math.Inf(1)
+	}
+	return float64(q.quota) / float64(q.period)
+}
+
+// readV1CPUQuota reads the CPU quota and period for a given cgroup v1 path.
+func readV1CPUQuota(fsys fs.FS, dir string) (cpuQuota, error) {
+	quotaFile := path.Join(dir, "cpu.cfs_quota_us")
+	periodFile := path.Join(dir, "cpu.cfs_period_us")
+
+	// Some partial setups have a period but no quota, which is unlimited at
+	// this level. The period is still required.
+	quota, err := readIntFromFile(fsys, quotaFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		quota, err = cgroupV1UnlimitedQuota, nil
+	}
+	if err != nil {
+		return cpuQuota{}, err
+	}
+
+	period, err := readIntFromFile(fsys, periodFile)
+	if err != nil {
+		return cpuQuota{}, err
+	}
+	if period == 0 {
+		return cpuQuota{}, &ParseError{File: periodFile, Content: "0", Err: errors.New("period is zero")}
+	}
+
+	// A quota of -1 in v1 means the cgroup has unlimited CPU time.
+	if parseUnlimited(quota) {
+		return cpuQuota{quota: -1, period: period}, nil
+	}
+
+	return cpuQuota{quota: quota, period: period}, nil
+}
+
+// rereadCPUQuota wraps readQuota to read each quota twice, returning
+// ErrTornRead if the two reads disagree.
+func rereadCPUQuota(readQuota func(fs.FS, string) (cpuQuota, error)) func(fs.FS, string) (cpuQuota, error) {
+	return func(fsys fs.FS, dir string) (cpuQuota, error) {
+		first, err := readQuota(fsys, dir)
+		if err != nil {
+			return cpuQuota{}, err
+		}
+		second, err := readQuota(fsys, dir)
+		if err != nil {
+			return cpuQuota{}, err
+		}
+		if first != second {
+			return cpuQuota{}, fmt.Errorf("%w: /%s: quota=%d period=%d, then quota=%d period=%d",
+				ErrTornRead, dir, first.quota, first.period, second.quota, second.period)
+		}
+		return first, nil
+	}
+}
+
+// readV2CPUQuota reads the CPU quota and period for a given cgroup v2 path.
+func readV2CPUQuota(fsys fs.FS, dir string) (cpuQuota, error) {
+	quota, period, err := readV2CPUMax(fsys, dir)
+	if err != nil {
+		return cpuQuota{}, err
+	}
+	return cpuQuota{quota: quota, period: period}, nil
+}
+
+// withinDir reports whether p is dir or a descendant of it.
+func withinDir(p, dir string) bool {
+	return dir == "." || p == dir || strings.HasPrefix(p, dir+"/")
+}
+
+// calculateV1CPUQuota computes the CPU quota for a given cgroup v1 path.
+func calculateV1CPUQuota(fsys fs.FS, dir string) (float64, error) {
+	q, err := readV1CPUQuota(fsys, dir)
+	if err != nil {
+		return 0, err
+	}
+	return q.limit(), nil
+}
+
+// calculateV1CPUBurst computes the CPU quota plus burst budget for a given
+// cgroup v1 path.
+func calculateV1CPUBurst(fsys fs.FS, dir string) (float64, error) {
+	q, err := readV1CPUQuota(fsys, dir)
+	if err != nil {
+		return 0, err
+	}
+	if q.quota < 0 {
+		return math.Inf(1), nil
+	}
+
+	burst, err := readIntFromFile(fsys, path.Join(dir, "cpu.cfs_burst_us"))
+	if errors.Is(err, fs.ErrNotExist) {
+		// Burst requires Linux 5.14 or newer.
+		return q.limit(), nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return float64(q.quota+burst) / float64(q.period), nil
+}
+
+// calculateV2CPUQuota computes the CPU quota for a given cgroup v2 path.
+func calculateV2CPUQuota(fsys fs.FS, dir string) (float64, error) {
+	q, err := readV2CPUQuota(fsys, dir)
+	if err != nil {
+		return 0, err
+	}
+	return q.limit(), nil
+}
+
+// calculateV2CPUBurst computes the CPU quota plus burst budget for a given
+// cgroup v2 path.
+func calculateV2CPUBurst(fsys fs.FS, dir string) (float64, error) {
+	quota, period, err := readV2CPUMax(fsys, dir)
+	if err != nil {
+		return 0, err
+	}
+	if quota < 0 {
+		return math.Inf(1), nil
+	}
+
+	burst, err := readIntFromFile(fsys, path.Join(dir, "cpu.max.burst"))
+	if errors.Is(err, fs.ErrNotExist) {
+		// Burst requires Linux 5.14 or newer.
+		burst = 0
+	} else if err != nil {
+		return 0, err
+	}
+
+	return float64(quota+burst) / float64(period), nil
+}
+
+// calculateV1CPUShares reads cpu.shares for a given cgroup v1 path, relative
+// to the default of 1024.
+func calculateV1CPUShares(fsys fs.FS, dir string) (float64, error) {
+	shares, err := readIntFromFile(fsys, path.Join(dir, "cpu.shares"))
+	if err != nil {
+		return 0, err
+	}
+	return float64(shares) / cgroupV1DefaultShares, nil
+}
+
+// calculateV2CPUWeight reads cpu.weight for a given cgroup v2 path, relative
+// to the default of 100.
+func calculateV2CPUWeight(fsys fs.FS, dir string) (float64, error) {
+	weight, err := readIntFromFile(fsys, path.Join(dir, "cpu.weight"))
+	if err != nil {
+		return 0, err
+	}
+	return float64(weight) / cgroupV2DefaultWeight, nil
+}
+
+// readV2CPUMax parses cpu.max for a given cgroup v2 path. A quota of "max" is
+// returned as -1.
+func readV2CPUMax(fsys fs.FS, dir string) (quota, period int64, err error) {
+	maxFile := path.Join(dir, "cpu.max")
+
+	parts, content, err := readFields(fsys, maxFile)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	switch {
+	case len(parts) == 0:
+		return 0, 0, &ParseError{File: maxFile, Content: content, Err: errors.New("empty")}
+	case len(parts) == 1 && parts[0] == "max":
+		// Some kernels omit the period when there is no quota.
+		return -1, cgroupV2DefaultPeriod, nil
+	case len(parts) != 2:
+		return 0, 0, &ParseError{File: maxFile, Content: content, Err: errors.New(`expected "$MAX $PERIOD"`)}
+	}
+
+	period, err = strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, 0, &ParseError{File: maxFile, Content: content, Err: err}
+	}
+	if period <= 0 {
+		return 0, 0, &ParseError{File: maxFile, Content: content, Err: errors.New("period is not positive")}
+	}
+
+	if parts[0] == "max" {
+		return -1, period, nil
+	}
+
+	quota, err = strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, 0, &ParseError{File: maxFile, Content: content, Err: err}
+	}
+	if quota < 0 {
+		return 0, 0, &ParseError{File: maxFile, Content: content, Err: errors.New("quota is negative")}
+	}
+
+	return quota, period, nil
+}
+
+// readFields reads a single-line cgroup file, such as cpu.max or
+// cpu.cfs_quota_us, and returns its whitespace-separated fields along with
+// the raw content for errors. The value files all parse through it so that
+// leading and trailing whitespace, including the newline, is ignored alike
+// in each. A file with more than one line is an error.
+func readFields(fsys fs.FS, filePath string) (fields []string, content string, err error) {
+	b, err := fs.ReadFile(fsys, filePath)
+	if err != nil {
+		return nil, "", err
+	}
+	content = string(b)
+	line := strings.TrimSpace(content)
+	if strings.Contains(line, "\n") {
+		return nil, content, &ParseError{File: filePath, Content: content, Err: errors.New("expected a single line")}
+	}
+	return strings.Fields(line), content, nil
+}
+
+// readValue reads a cgroup file containing a single value.
+func readValue(fsys fs.FS, filePath string) (val, content string, err error) {
+	fields, content, err := readFields(fsys, filePath)
+	if err != nil {
+		return "", "", err
+	}
+	if len(fields) != 1 {
+		return "", "", &ParseError{File: filePath, Content: content, Err: errors.New("expected a single value")}
+	}
+	return fields[0], content, nil
+}
+
+// readIntFromFile is a helper to read an integer from a file.
+func readIntFromFile(fsys fs.FS, filePath string) (int64, error) {
+	s, content, err := readValue(fsys, filePath)
+	if err != nil {
+		return 0, err
+	}
+	val, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, &ParseError{File: filePath, Content: content, Err: err}
+	}
+	return val, nil
+}
+
+// cgroupV1UnlimitedMemory is math.MaxInt64 rounded down to a 64KiB page, the
+// smallest value cgroup v1 reports in memory.limit_in_bytes when no limit is
+// set. Smaller pages round down less, e.g. to 9223372036854771712 with 4KiB
+// pages.
+const cgroupV1UnlimitedMemory = math.MaxInt64 &^ (64<<10 - 1)
+
+// parseUnlimited reports whether val is one of the sentinels cgroup files use
+// for "no limit": -1, as in cpu.cfs_quota_us, or math.MaxInt64 rounded down
+// to the page size, as in memory.limit_in_bytes.
+func parseUnlimited(val int64) bool {
+	return val == cgroupV1UnlimitedQuota || val >= cgroupV1UnlimitedMemory
+}
+
+// readMaxFile reads a limit that is either an integer or "max", as in v2
+// memory.max and pids.max. "max" and the sentinels recognized by
+// parseUnlimited are returned as +Inf.
+func readMaxFile(fsys fs.FS, filePath string) (float64, error) {
+	val, content, err := readValue(fsys, filePath)
+	if err != nil {
+		return 0, err
+	}
+	if val == "max" {
+		return math.Inf(1), nil
+	}
+
+	limit, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return 0, &ParseError{File: filePath, Content: content, Err: err}
+	}
+	if parseUnlimited(limit) {
+		return math.Inf(1), nil
+	}
+	return float64(limit), nil
+}
@@ -0,0 +1,279 @@
+package cgroup
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+	"testing/fstest"
+)
+
+func TestCalculateV1CPUQuota(t *testing.T) {
+	cases := []struct {
+		name          string
+		quota, period string
+		want          float64
+	}{
+		{"limited", "150000\n", "100000\n", 1.5},
+		{"unlimited", "-1\n", "100000\n", math.Inf(1)},
+		{"short period", "5000\n", "10000\n", 0.5},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			fsys := fstest.MapFS{
+				"cpu/cpu.cfs_quota_us":  {Data: []byte(tc.quota)},
+				"cpu/cpu.cfs_period_us": {Data: []byte(tc.period)},
+			}
+			got, err := calculateV1CPUQuota(fsys, "cpu")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("calculateV1CPUQuota = %g, want %g", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCalculateV1CPUQuotaMissingQuota(t *testing.T) {
+	// A period without a quota is unlimited at this level.
+	fsys := fstest.MapFS{"cpu/cpu.cfs_period_us": {Data: []byte("100000\n")}}
+	got, err := calculateV1CPUQuota(fsys, "cpu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !math.IsInf(got, 1) {
+		t.Errorf("calculateV1CPUQuota = %g, want +Inf", got)
+	}
+}
+
+func TestCalculateV1CPUQuotaInvalid(t *testing.T) {
+	cases := []struct {
+		name  string
+		files fstest.MapFS
+	}{
+		{"zero period", fstest.MapFS{
+			"cpu/cpu.cfs_quota_us":  {Data: []byte("100000\n")},
+			"cpu/cpu.cfs_period_us": {Data: []byte("0\n")},
+		}},
+		{"garbage quota", fstest.MapFS{
+			"cpu/cpu.cfs_quota_us":  {Data: []byte("lots\n")},
+			"cpu/cpu.cfs_period_us": {Data: []byte("100000\n")},
+		}},
+		{"missing period", fstest.MapFS{
+			"cpu/cpu.cfs_quota_us": {Data: []byte("100000\n")},
+		}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got, err := calculateV1CPUQuota(tc.files, "cpu"); err == nil {
+				t.Errorf("calculateV1CPUQuota = %g, want an error", got)
+			}
+		})
+	}
+}
+
+func TestCalculateV2CPUQuota(t *testing.T) {
+	cases := []struct {
+		name   string
+		cpuMax string
+		want   float64
+	}{
+		{"limited", "250000 100000\n", 2.5},
+		{"unlimited", "max 100000\n", math.Inf(1)},
+		{"short period", "50000 10000\n", 5},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			fsys := fstest.MapFS{"kube/cpu.max": {Data: []byte(tc.cpuMax)}}
+			got, err := calculateV2CPUQuota(fsys, "kube")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("calculateV2CPUQuota(%q) = %g, want %g", tc.cpuMax, got, tc.want)
+			}
+		})
+	}
+}
+
+func BenchmarkCalculateV2CPUQuota(b *testing.B) {
+	fsys, dir := deepFS()
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := calculateV2CPUQuota(fsys, dir); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestReadV2CPUMax(t *testing.T) {
+	cases := []struct {
+		content       string
+		quota, period int64
+		wantErr       bool
+	}{
+		{content: "max 100000\n", quota: -1, period: 100000},
+		{content: "max", quota: -1, period: 100000},
+		{content: "max\n", quota: -1, period: 100000},
+		{content: "200000 100000", quota: 200000, period: 100000},
+		{content: "  200000\t100000  \n\n", quota: 200000, period: 100000},
+		{content: "200000 100000\r\n", quota: 200000, period: 100000},
+		{content: "", wantErr: true},
+		{content: "\n", wantErr: true},
+		{content: "200000", wantErr: true},
+		{content: "200000 100000 1", wantErr: true},
+		{content: "lots 100000", wantErr: true},
+		{content: "200000 often", wantErr: true},
+		{content: "max 0", wantErr: true},
+		{content: "-5 100000", wantErr: true},
+		{content: "200000 100000\n200000 100000\n", wantErr: true},
+		{content: "200000\n100000\n", wantErr: true},
+	}
+	for _, tc := range cases {
+		fsys := fstest.MapFS{"kube/cpu.max": {Data: []byte(tc.content)}}
+		quota, period, err := readV2CPUMax(fsys, "kube")
+		if tc.wantErr {
+			var perr *ParseError
+			if !errors.As(err, &perr) {
+				t.Errorf("readV2CPUMax(%q) = %d, %d, %v, want a ParseError", tc.content, quota, period, err)
+			} else if perr.File != "kube/cpu.max" {
+				t.Errorf("readV2CPUMax(%q) error names %q, want kube/cpu.max", tc.content, perr.File)
+			}
+			continue
+		}
+		if err != nil || quota != tc.quota || period != tc.period {
+			t.Errorf("readV2CPUMax(%q) = %d, %d, %v, want %d, %d", tc.content, quota, period, err, tc.quota, tc.period)
+		}
+	}
+}
+
+func TestReadIntFromFile(t *testing.T) {
+	cases := []struct {
+		content string
+		want    int64
+		wantErr bool
+	}{
+		{content: "150000", want: 150000},
+		{content: "150000\n", want: 150000},
+		{content: "150000 \n", want: 150000},
+		{content: "\t150000\r\n\n", want: 150000},
+		{content: "-1\n", want: -1},
+		{content: "", wantErr: true},
+		{content: "\n", wantErr: true},
+		{content: "150000 1\n", wantErr: true},
+		{content: "150000x\n", wantErr: true},
+		{content: "150000\n150000\n", wantErr: true},
+	}
+	for _, tc := range cases {
+		fsys := fstest.MapFS{"cpu/cpu.cfs_quota_us": {Data: []byte(tc.content)}}
+		got, err := readIntFromFile(fsys, "cpu/cpu.cfs_quota_us")
+		if tc.wantErr {
+			var perr *ParseError
+			if !errors.As(err, &perr) {
+				t.Errorf("readIntFromFile(%q) = %d, %v, want a ParseError", tc.content, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("readIntFromFile(%q) = %d, %v, want %d", tc.content, got, err, tc.want)
+		}
+	}
+}
+
+func FuzzReadV2CPUMax(f *testing.F) {
+	for _, seed := range []string{
+		"max 100000\n",
+		"max",
+		"",
+		"200000",
+		"200000 100000\n",
+		"max 0",
+		"-5 100000",
+		"1 2 3",
+		"200000 100000\n200000 100000\n",
+		"200000 -1\n",
+		"99999999999999999999 100000",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, content string) {
+		fsys := fstest.MapFS{"kube/cpu.max": {Data: []byte(content)}}
+		quota, period, err := readV2CPUMax(fsys, "kube")
+		if err != nil {
+			var perr *ParseError
+			if !errors.As(err, &perr) {
+				t.Fatalf("readV2CPUMax(%q) = %v, want a ParseError", content, err)
+			}
+			return
+		}
+		if period <= 0 || quota < -1 {
+			t.Fatalf("readV2CPUMax(%q) = %d, %d", content, quota, period)
+		}
+
+		// Writing the values back the way the kernel does reads the same.
+		field := "max"
+		if quota >= 0 {
+			field = fmt.Sprint(quota)
+		}
+		fsys["kube/cpu.max"] = &fstest.MapFile{Data: []byte(fmt.Sprintf("%s %d\n", field, period))}
+		q, p, err := readV2CPUMax(fsys, "kube")
+		if err != nil || q != quota || p != period {
+			t.Errorf("readV2CPUMax(%q) = %d, %d, but rereading gives %d, %d, %v", content, quota, period, q, p, err)
+		}
+	})
+}
+
+func TestParseUnlimited(t *testing.T) {
+	cases := []struct {
+		val  int64
+		want bool
+	}{
+		{-1, true},
+		{math.MaxInt64, true},
+		{9223372036854771712, true}, // 4KiB pages
+		{9223372036854710272, true}, // 64KiB pages
+		{0, false},
+		{1 << 30, false},
+		{-2, false},
+		{9223372036854710271, false},
+	}
+	for _, tc := range cases {
+		if got := parseUnlimited(tc.val); got != tc.want {
+			t.Errorf("parseUnlimited(%d) = %t, want %t", tc.val, got, tc.want)
+		}
+	}
+}
+
+func TestReadMaxFile(t *testing.T) {
+	cases := []struct {
+		content string
+		want    float64
+	}{
+		{"max\n", math.Inf(1)},
+		{"100\n", 100},
+		{"1073741824\n", 1 << 30},
+		{"9223372036854771712\n", math.Inf(1)},
+		{"-1\n", math.Inf(1)},
+		{"max", math.Inf(1)},
+		{" 100 \r\n", 100},
+	}
+	for _, tc := range cases {
+		fsys := fstest.MapFS{"pids.max": {Data: []byte(tc.content)}}
+		got, err := readMaxFile(fsys, "pids.max")
+		if err != nil || got != tc.want {
+			t.Errorf("readMaxFile(%q) = %g, %v, want %g", tc.content, got, err, tc.want)
+		}
+	}
+}
+
+func TestReadMaxFileInvalid(t *testing.T) {
+	for _, content := range []string{"", "max max\n", "100\n100\n", "lots\n"} {
+		fsys := fstest.MapFS{"pids.max": {Data: []byte(content)}}
+		got, err := readMaxFile(fsys, "pids.max")
+		var perr *ParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("readMaxFile(%q) = %g, %v, want a ParseError", content, got, err)
+		}
+	}
+}
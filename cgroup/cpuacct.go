@@ -0,0 +1,66 @@
+package cgroup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"time"
+)
+
+// CPUUsage returns the cumulative CPU time consumed by the current process's
+// own cgroup, or by PID's if set, from the cgroup v1 cpuacct.usage file. It
+// is not a limit, but alongside Throttling it shows whether a cgroup that is
+// limited by its quota actually uses it. It returns ErrNotInCgroup if the
+// process is not in a cgroup, and ErrCgroupUnsupported if there is no cgroup
+// v1 cpuacct hierarchy.
+func (d *Detector) CPUUsage() (time.Duration, error) {
+	return d.CPUUsageContext(context.Background())
+}
+
+// CPUUsageContext is like CPUUsage but stops reading cgroup files and
+// returns ctx.Err() once ctx is done.
+func (d *Detector) CPUUsageContext(ctx context.Context) (time.Duration, error) {
+	fsys, m, cgroups, err := d.resolve(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return cpuUsage(fsys, cgroups, m)
+}
+
+// cpuUsage implements CPUUsageContext for the process whose cgroups are
+// listed in cgroups.
+func cpuUsage(fsys fs.FS, cgroups procCgroups, m mounts) (time.Duration, error) {
+	h, v1 := m.cgroupV1(fsys, "cpuacct")
+	if !v1 {
+		if len(m.hierarchiesFor(fsys, "cpuacct")) == 0 {
+			return 0, ErrNotInCgroup
+		}
+		return 0, fmt.Errorf("%w: cpuacct.usage is only available in cgroup v1", ErrCgroupUnsupported)
+	}
+
+	cgroupPath, err := cgroups.path("cpuacct")
+	if err != nil {
+		return 0, err
+	}
+	usage, err := readIntFromFile(fsys, path.Join(h.dir(cgroupPath), "cpuacct.usage"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, fmt.Errorf("%w: no cpuacct.usage", ErrCgroupUnsupported)
+	}
+	if err != nil {
+		return 0, err
+	}
+	// cpuacct.usage is in nanoseconds.
+	return time.Duration(usage), nil
+}
+
+// CPUUsage calls CPUUsage on a Detector reading from the host.
+func CPUUsage() (time.Duration, error) {
+	return (&Detector{}).CPUUsage()
+}
+
+// CPUUsageContext calls CPUUsageContext on a Detector reading from the host.
+func CPUUsageContext(ctx context.Context) (time.Duration, error) {
+	return (&Detector{}).CPUUsageContext(ctx)
+}
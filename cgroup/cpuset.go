@@ -0,0 +1,151 @@
+package cgroup
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// onlineCPUsPath lists every CPU online on the host
+const onlineCPUsPath = "sys/devices/system/cpu/online"
+
+// getCpusetLimit returns the number of CPUs the cpuset of the process whose
+// cgroups are listed in cgroups allows and the cgroup that set it. The limit
+// is 0 if the cpuset does not restrict the process to fewer than all online
+// CPUs, or if there is no cpuset controller.
+func getCpusetLimit(fsys fs.FS, cgroups procCgroups, m mounts) (limitAt, error) {
+	var minLimit limitAt
+	var denied []string
+	for _, h := range m.hierarchiesFor(fsys, "cpuset") {
+		limit, err := getHierarchyCpusetLimit(fsys, cgroups, h)
+		if err != nil {
+			return limitAt{}, err
+		}
+		denied = append(denied, limit.denied...)
+		minLimit = minLimitAt(minLimit, limit)
+	}
+	minLimit.denied = denied
+	return minLimit, nil
+}
+
+// getHierarchyCpusetLimit is like getCpusetLimit for a single hierarchy.
+func getHierarchyCpusetLimit(fsys fs.FS, cgroups procCgroups, h hierarchy) (limitAt, error) {
+	controller, version := "cpuset", "v1"
+	effective, configured := "cpuset.effective_cpus", "cpuset.cpus"
+	if h.v2 {
+		controller, version = "", "v2"
+		effective = "cpuset.cpus.effective"
+	}
+
+	cgroupPath, err := cgroups.path(controller)
+	if errors.Is(err, ErrCgroupUnsupported) {
+		return limitAt{}, nil
+	}
+	if err != nil {
+		return limitAt{}, fmt.Errorf("failed to get cgroup %s path: %w", version, err)
+	}
+	dir := h.dir(cgroupPath)
+
+	// The effective set is the one actually granted, after inheriting from
+	// and intersecting with every ancestor.
+	count, err := readCPUListFile(fsys, path.Join(dir, effective))
+	if errors.Is(err, fs.ErrNotExist) {
+		// Older kernels and some snapshots only have the configured set,
+		// which is empty when it is inherited from the parent, so use the
+		// nearest ancestor's that is not.
+		nearest, err := walkHierarchy(fsys, dir, cpusetCount(configured), h.mountPoint, true)
+		if err != nil {
+			return limitAt{}, err
+		}
+		if len(nearest.failed) > 0 {
+			return limitAt{}, nearest.failed[0]
+		}
+		if nearest.limit == 0 {
+			// The cpuset controller is not enabled, or no level restricts
+			// the set, which means all CPUs.
+			return limitAt{denied: nearest.denied}, nil
+		}
+		count, dir = int(nearest.limit), nearest.path
+	} else if errors.Is(err, fs.ErrPermission) {
+		return limitAt{denied: []string{dir}}, nil
+	} else if err != nil {
+		return limitAt{}, err
+	}
+	if count == 0 {
+		// An empty cpuset means all CPUs.
+		return limitAt{}, nil
+	}
+
+	// A cpuset covering every online CPU is not a restriction.
+	if online, err := readCPUListFile(fsys, onlineCPUsPath); err == nil && count >= online {
+		return limitAt{}, nil
+	}
+
+	return limitAt{limit: float64(count), path: dir}, nil
+}
+
+// cpusetCount returns a limitFunc counting the CPUs in the cpuset file name.
+// An empty set inherits its parent's, so it is treated as setting no limit.
+func cpusetCount(name string) limitFunc {
+	return func(fsys fs.FS, dir string) (float64, error) {
+		count, err := readCPUListFile(fsys, path.Join(dir, name))
+		if err != nil {
+			return 0, err
+		}
+		if count == 0 {
+			return math.Inf(1), nil
+		}
+		return float64(count), nil
+	}
+}
+
+// readCPUListFile reads a file in the kernel's CPU list format and returns
+// the number of CPUs it contains.
+func readCPUListFile(fsys fs.FS, filePath string) (int, error) {
+	fields, content, err := readFields(fsys, filePath)
+	if err != nil {
+		return 0, err
+	}
+	if len(fields) > 1 {
+		return 0, &ParseError{File: filePath, Content: content, Err: errors.New("expected a single CPU list")}
+	}
+	count, err := parseCPUList(strings.Join(fields, ""))
+	if err != nil {
+		return 0, &ParseError{File: filePath, Content: content, Err: err}
+	}
+	return count, nil
+}
+
+// parseCPUList counts the CPUs in a list such as "0-3,8". An empty list
+// contains zero CPUs.
+func parseCPUList(list string) (int, error) {
+	list = strings.TrimSpace(list)
+	if list == "" {
+		return 0, nil
+	}
+
+	count := 0
+	for _, r := range strings.Split(list, ",") {
+		lo, hi, isRange := strings.Cut(r, "-")
+		first, err := strconv.Atoi(lo)
+		if err != nil {
+			return 0, err
+		}
+		last := first
+		if isRange {
+			last, err = strconv.Atoi(hi)
+			if err != nil {
+				return 0, err
+			}
+		}
+		if last < first {
+			return 0, fmt.Errorf("invalid range %q", r)
+		}
+		count += last - first + 1
+	}
+	return count, nil
+}
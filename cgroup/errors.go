@@ -0,0 +1,53 @@
+package cgroup
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	// ErrNotInCgroup is returned when no cgroup hierarchy is mounted or the
+	// process's cgroup membership cannot be found, e.g. on a host without
+	// cgroups.
+	ErrNotInCgroup = errors.New("not in a cgroup")
+
+	// ErrCgroupUnsupported is returned when a cgroup hierarchy is mounted
+	// but the controller needed is not available to the process.
+	ErrCgroupUnsupported = errors.New("cgroup controller unsupported")
+
+	// ErrPlatformUnsupported is returned when detecting limits from the
+	// host on a platform other than Linux or Windows.
+	ErrPlatformUnsupported = errors.New("cgroup detection unsupported on this platform")
+
+	// ErrNoProcess is returned when the process a Detector's PID refers to
+	// does not exist, or exits while its limits are being read.
+	ErrNoProcess = errors.New("no such process")
+
+	// ErrTornRead is returned when Detector.Reread is set and a cgroup file
+	// changes between two consecutive reads.
+	ErrTornRead = errors.New("cgroup file changed while being read")
+)
+
+// ParseError is returned when a cgroup or proc file has unexpected
+// contents.
+type ParseError struct {
+	// File is the path of the file, relative to the Detector's filesystem.
+	File string
+	// Content is the raw content that failed to parse.
+	Content string
+	// Err is the underlying error, if any.
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	// Files end in a newline, which would split the message.
+	msg := "invalid format in " + e.File + ": " + strings.TrimSpace(e.Content)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,105 @@
+package cgroup
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// ErrGOMAXPROCSEnv is returned by SetGOMAXPROCS when the $GOMAXPROCS
+// environment variable is set and force was not requested.
+var ErrGOMAXPROCSEnv = errors.New("$GOMAXPROCS is set")
+
+// SetGOMAXPROCS sets runtime.GOMAXPROCS to the adjusted value and returns
+// the new value. If the process is not in a cgroup or its cgroup does not
+// limit CPU, GOMAXPROCS is left unchanged and its current value is returned.
+//
+// If $GOMAXPROCS is set it is respected and ErrGOMAXPROCSEnv is returned
+// unless force is true.
+func (d *Detector) SetGOMAXPROCS(force bool) (int, error) {
+	if _, ok := os.LookupEnv("GOMAXPROCS"); ok && !force {
+		return runtime.GOMAXPROCS(-1), ErrGOMAXPROCSEnv
+	}
+
+	procs, err := d.AdjustedGOMAXPROCS()
+	if errors.Is(err, ErrNotInCgroup) {
+		return runtime.GOMAXPROCS(-1), nil
+	}
+	if err != nil {
+		return runtime.GOMAXPROCS(-1), err
+	}
+	if procs == 0 {
+		return runtime.GOMAXPROCS(-1), nil
+	}
+
+	runtime.GOMAXPROCS(procs)
+	return procs, nil
+}
+
+// SetGOMAXPROCS calls SetGOMAXPROCS on a Detector reading from the host
+// without forcing, so $GOMAXPROCS is respected.
+func SetGOMAXPROCS() (int, error) {
+	return (&Detector{}).SetGOMAXPROCS(false)
+}
+
+// GOMAXPROCS returns the recommended GOMAXPROCS without reporting errors: a
+// valid $GOMAXPROCS if set, as the runtime prefers it, otherwise the adjusted
+// value. It falls back to runtime.NumCPU() if the process is not in a
+// cgroup, its cgroup does not limit CPU, or the limits cannot be read for
+// any reason. Use AdjustedGOMAXPROCS to tell these cases apart.
+func (d *Detector) GOMAXPROCS() int {
+	if n, ok, err := EnvGOMAXPROCS(); ok && err == nil {
+		return n
+	}
+	procs, err := d.AdjustedGOMAXPROCS()
+	if err != nil || procs == 0 {
+		return runtime.NumCPU()
+	}
+	return procs
+}
+
+// GOMAXPROCS calls GOMAXPROCS on a Detector reading from the host, e.g.
+// runtime.GOMAXPROCS(cgroup.GOMAXPROCS()) in main.
+func GOMAXPROCS() int {
+	return (&Detector{}).GOMAXPROCS()
+}
+
+// EnvGOMAXPROCS returns the value of $GOMAXPROCS, which the runtime
+// prioritizes over any computed value. ok is false if it is unset. An error
+// is returned if it is set but is not a positive integer, in which case the
+// runtime ignores it.
+func EnvGOMAXPROCS() (n int, ok bool, err error) {
+	v, ok := os.LookupEnv("GOMAXPROCS")
+	if !ok {
+		return 0, false, nil
+	}
+	n, err = strconv.Atoi(v)
+	if f, ferr := strconv.ParseFloat(v, 64); err != nil && ferr == nil && f > 0 && f < math.MaxInt32 {
+		// Tooling doing float math, e.g. in a Helm template, can produce
+		// values like "1.5".
+		return 0, true, fmt.Errorf("$GOMAXPROCS=%q is not an integer and is ignored by the runtime (rounded up it would be %d)", v, int(math.Ceil(f)))
+	}
+	if err != nil || n <= 0 {
+		return 0, true, fmt.Errorf("$GOMAXPROCS=%q is not a positive integer and is ignored by the runtime", v)
+	}
+	return n, true, nil
+}
+
+// EnvCPULimit returns the CPU limit in the environment variable name, in
+// CPUs, e.g. "1.5". ok is false if it is unset or empty. An error is
+// returned if it is set but is not a positive number.
+func EnvCPULimit(name string) (limit float64, ok bool, err error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return 0, false, nil
+	}
+	limit, err = strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil || !(limit > 0) || math.IsInf(limit, 1) {
+		return 0, true, fmt.Errorf("$%s=%q is not a positive number of CPUs", name, v)
+	}
+	return limit, true, nil
+}
@@ -0,0 +1,205 @@
+package cgroup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+)
+
+// burstWarnRatio is the burst budget, as a fraction of the period, above
+// which Detect warns that CPU is provisioned to be bursty.
+const burstWarnRatio = 0.5
+
+// Info is everything that goes into a process's GOMAXPROCS, as reported by
+// Detect.
+type Info struct {
+	// NumCPU is runtime.NumCPU() of the calling process.
+	NumCPU int
+	// GOMAXPROCSEnv is the value of $GOMAXPROCS, or "" if it is unset.
+	GOMAXPROCSEnv string
+	// RuntimeGOMAXPROCS is runtime.GOMAXPROCS(-1) of the calling process.
+	RuntimeGOMAXPROCS int
+
+	// CgroupVersion is "v1", "v2", "hybrid", or "none". See CgroupVersion.
+	CgroupVersion string
+	// InCgroup is false if the process is not in a cgroup, in which case
+	// the limits below are 0 unless the CPU limit is from CPULimitEnv.
+	InCgroup bool
+	// GVisor is true if the process runs under gVisor, whose emulated
+	// cgroups may not reflect how the sandbox enforces limits.
+	GVisor bool
+
+	// EffectiveCPU is CPU.Effective, the steady-state CPU limit, or 0 if the
+	// process's cgroup does not limit CPU.
+	EffectiveCPU float64
+	// AdjustedGOMAXPROCS is the GOMAXPROCS derived from EffectiveCPU, or 0
+	// if it is 0. It ignores $GOMAXPROCS.
+	AdjustedGOMAXPROCS int
+	// LimitedByPath is CPU.LimitedBy, the cgroup directory that set
+	// EffectiveCPU.
+	LimitedByPath string
+	// MemoryLimit is the effective memory limit in bytes, or 0 if there is
+	// none.
+	MemoryLimit int64
+	// MemoryHigh is the effective cgroup v2 memory.high in bytes, above
+	// which the kernel reclaims memory, or 0 if there is none.
+	MemoryHigh int64
+
+	// CgroupPath is the process's cgroup, as CgroupPath returns, or "" if
+	// it cannot be read.
+	CgroupPath string
+	// PidsLimit is the effective pids.max, or 0 if there is none or it
+	// cannot be read.
+	PidsLimit int64
+	// Throttling is the throttling statistics of the process's cgroup, or
+	// nil if no cpu.stat reports them.
+	Throttling *ThrottleStats
+	// CPUUsage is the CPU time the process's cgroup has consumed, or nil if
+	// there is no cgroup v1 cpuacct.usage.
+	CPUUsage *time.Duration
+
+	// CPU is the full CPU limit that EffectiveCPU and LimitedByPath
+	// summarize.
+	CPU CPULimit
+
+	// Warnings describes likely misconfigurations and incomplete reads,
+	// such as an invalid $GOMAXPROCS or an unreadable cgroup level. It
+	// is empty, not nil, if there are none.
+	Warnings []string
+}
+
+// Detect returns the CPU and memory limits of the current process, or of PID
+// if set, along with the host and runtime values they are applied to and
+// the cgroup's other informational limits and statistics. Not
+// being in a cgroup is not an error. On error the Info is filled in as far
+// as detection got.
+func (d *Detector) Detect() (Info, error) {
+	return d.DetectContext(context.Background())
+}
+
+// DetectContext is like Detect but stops reading cgroup files and returns
+// ctx.Err() once ctx is done.
+func (d *Detector) DetectContext(ctx context.Context) (Info, error) {
+	// Every limit below is read relative to a single parse of the mounts
+	// and the process's cgroups.
+	fsys, m, cgroups, resolveErr := d.resolve(ctx)
+
+	info := Info{
+		NumCPU:            runtime.NumCPU(),
+		GOMAXPROCSEnv:     os.Getenv("GOMAXPROCS"),
+		RuntimeGOMAXPROCS: runtime.GOMAXPROCS(-1),
+		CgroupVersion:     m.version(fsys),
+		GVisor:            d.GVisor(),
+		Warnings:          []string{},
+	}
+
+	if info.GVisor {
+		info.Warnings = append(info.Warnings, "running under gVisor; cgroup limits may not reflect actual enforcement")
+	}
+
+	// The runtime ignores an invalid $GOMAXPROCS.
+	if _, _, err := EnvGOMAXPROCS(); err != nil {
+		info.Warnings = append(info.Warnings, err.Error())
+	}
+	if d.CPULimitEnv != "" {
+		if _, _, err := EnvCPULimit(d.CPULimitEnv); err != nil {
+			info.Warnings = append(info.Warnings, fmt.Sprintf("%v, so it is ignored", err))
+		}
+	}
+
+	cpu, err := d.cpuContext(ctx, fsys, m, cgroups, resolveErr)
+	if errors.Is(err, ErrNotInCgroup) {
+		return info, nil
+	}
+	if err != nil {
+		return info, err
+	}
+	info.InCgroup = !errors.Is(cpu.CgroupErr, ErrNotInCgroup)
+	info.CPU = cpu
+	info.EffectiveCPU = cpu.Effective
+	if info.AdjustedGOMAXPROCS, err = d.Adjust(cpu.Effective); err != nil {
+		return info, err
+	}
+	info.LimitedByPath = cpu.LimitedBy
+	if cpu.CgroupErr != nil && info.InCgroup {
+		info.Warnings = append(info.Warnings, fmt.Sprintf("cannot read the cgroup CPU limit, using $%s instead: %v", cpu.FromEnv, cpu.CgroupErr))
+	}
+	for _, dir := range cpu.Unreadable {
+		info.Warnings = append(info.Warnings, fmt.Sprintf("permission denied reading CPU limits in /%s, the limit may be incomplete", dir))
+	}
+	for _, err := range cpu.ReadErrors {
+		info.Warnings = append(info.Warnings, fmt.Sprintf("skipping unreadable CPU limit, the limit may be incomplete: %v", err))
+	}
+	if ratio := cpu.BurstRatio(); ratio > burstWarnRatio {
+		info.Warnings = append(info.Warnings, fmt.Sprintf("burst budget is %gx the period (above %gx), so the cgroup can exceed its steady-state %g CPUs", ratio, burstWarnRatio, cpu.Effective))
+	}
+
+	if resolveErr != nil {
+		// The CPU limit is from CPULimitEnv or the platform, and the other
+		// limits cannot be read without the process's cgroups.
+		if !errors.Is(resolveErr, ErrNotInCgroup) {
+			info.Warnings = append(info.Warnings, fmt.Sprintf("cannot read the cgroup memory limit: %v", resolveErr))
+		}
+		return info, nil
+	}
+
+	mem, err := d.memoryLimit(fsys, cgroups, m)
+	if err != nil && !errors.Is(err, ErrNotInCgroup) {
+		if cpu.CgroupErr == nil {
+			return info, err
+		}
+		// The cgroup files are likely unreadable as a whole, but the CPU
+		// limit was still found.
+		info.Warnings = append(info.Warnings, fmt.Sprintf("cannot read the cgroup memory limit: %v", err))
+	}
+	info.MemoryLimit = mem
+
+	high, err := d.memoryHigh(fsys, cgroups, m)
+	if err != nil && !errors.Is(err, ErrNotInCgroup) {
+		if cpu.CgroupErr == nil {
+			return info, err
+		}
+		info.Warnings = append(info.Warnings, fmt.Sprintf("cannot read the cgroup memory.high: %v", err))
+	}
+	info.MemoryHigh = high
+
+	// The rest is informational, so failing to read it is not an error.
+	if p, err := cgroupPath(fsys, cgroups, m); err == nil {
+		info.CgroupPath = p
+	}
+	if pids, err := d.pidsLimit(fsys, cgroups, m); err == nil {
+		info.PidsLimit = pids
+	} else if !unsupported(err) {
+		info.Warnings = append(info.Warnings, "reading pids.max: "+err.Error())
+	}
+	if t, err := throttling(fsys, cgroups, m); err == nil {
+		info.Throttling = &t
+	} else if !unsupported(err) {
+		info.Warnings = append(info.Warnings, "reading cpu.stat: "+err.Error())
+	}
+	if usage, err := cpuUsage(fsys, cgroups, m); err == nil {
+		info.CPUUsage = &usage
+	} else if !unsupported(err) {
+		info.Warnings = append(info.Warnings, "reading cpuacct.usage: "+err.Error())
+	}
+	return info, nil
+}
+
+// unsupported reports whether err means the process's cgroups lack a
+// controller or file, rather than that reading it failed.
+func unsupported(err error) bool {
+	return errors.Is(err, ErrNotInCgroup) || errors.Is(err, ErrCgroupUnsupported)
+}
+
+// Detect calls Detect on a Detector reading from the host.
+func Detect() (Info, error) {
+	return (&Detector{}).Detect()
+}
+
+// DetectContext calls DetectContext on a Detector reading from the host.
+func DetectContext(ctx context.Context) (Info, error) {
+	return (&Detector{}).DetectContext(ctx)
+}
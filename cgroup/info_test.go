@@ -0,0 +1,140 @@
+package cgroup
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+func TestDetectWarnings(t *testing.T) {
+	cases := []struct {
+		name   string
+		denied []string
+		cpuMax string // of the leaf, if not empty
+		want   []string
+	}{
+		// The v2 root never has a cpu.max, so its absence is not worth a
+		// warning.
+		{"root lacks cpu.max", nil, "", nil},
+		{"middle level denied", []string{"sys/fs/cgroup/pod/cpu.max"}, "", []string{"permission denied reading CPU limits in /sys/fs/cgroup/pod, the limit may be incomplete"}},
+		{"leaf malformed", nil, "lots 100000\n", []string{"skipping unreadable CPU limit, the limit may be incomplete: invalid format in sys/fs/cgroup/pod/app/cpu.max: lots 100000: strconv.ParseInt: parsing \"lots\": invalid syntax"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			// A valid $GOMAXPROCS, so the host's cannot add a warning.
+			t.Setenv("GOMAXPROCS", "4")
+			fsys := nestedV2FS()
+			if tc.cpuMax != "" {
+				fsys["sys/fs/cgroup/pod/app/cpu.max"] = &fstest.MapFile{Data: []byte(tc.cpuMax)}
+			}
+			d := &Detector{FS: deniedFS{FS: fsys, denied: tc.denied}}
+			info, err := d.Detect()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(info.Warnings, tc.want) {
+				t.Errorf("Warnings = %q, want %q", info.Warnings, tc.want)
+			}
+		})
+	}
+}
+
+// TestDetectFixtures runs detection against the captured trees in testdata,
+// one per layout seen in the wild.
+func TestDetectFixtures(t *testing.T) {
+	cases := []struct {
+		name        string
+		version     string
+		effective   float64
+		adjusted    int
+		limitedBy   string
+		memoryLimit int64
+		unlimited   bool
+	}{
+		{
+			name:        "v1",
+			version:     "v1",
+			effective:   2,
+			adjusted:    2,
+			limitedBy:   "sys/fs/cgroup/cpu,cpuacct/docker/abc123",
+			memoryLimit: 512 << 20,
+		},
+		{
+			// Levels with a period but no quota, as on partially
+			// configured v1 nodes.
+			name:      "v1-partial",
+			version:   "v1",
+			effective: 3,
+			adjusted:  3,
+			limitedBy: "sys/fs/cgroup/cpu,cpuacct/kubepods/pod1",
+		},
+		{
+			name:        "v2",
+			version:     "v2",
+			effective:   2.5,
+			adjusted:    3,
+			limitedBy:   "sys/fs/cgroup/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod1234.slice",
+			memoryLimit: 1 << 30,
+		},
+		{
+			name:        "hybrid",
+			version:     "hybrid",
+			effective:   1.5,
+			adjusted:    2,
+			limitedBy:   "sys/fs/cgroup/cpu,cpuacct/system.slice/app.service",
+			memoryLimit: 256 << 20,
+		},
+		{
+			name:        "namespaced",
+			version:     "v2",
+			effective:   0.5,
+			adjusted:    2,
+			limitedBy:   "sys/fs/cgroup",
+			memoryLimit: 128 << 20,
+		},
+		{
+			name:      "unlimited",
+			version:   "v2",
+			unlimited: true,
+		},
+		{
+			name:      "cpuset",
+			version:   "v2",
+			effective: 3,
+			adjusted:  3,
+			limitedBy: "sys/fs/cgroup/pinned",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := &Detector{FS: os.DirFS(filepath.Join("testdata", tc.name))}
+			info, err := d.Detect()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !info.InCgroup {
+				t.Error("InCgroup = false, want true")
+			}
+			if info.CgroupVersion != tc.version {
+				t.Errorf("CgroupVersion = %q, want %q", info.CgroupVersion, tc.version)
+			}
+			if info.EffectiveCPU != tc.effective {
+				t.Errorf("EffectiveCPU = %g, want %g", info.EffectiveCPU, tc.effective)
+			}
+			if info.AdjustedGOMAXPROCS != tc.adjusted {
+				t.Errorf("AdjustedGOMAXPROCS = %d, want %d", info.AdjustedGOMAXPROCS, tc.adjusted)
+			}
+			if info.LimitedByPath != tc.limitedBy {
+				t.Errorf("LimitedByPath = %q, want %q", info.LimitedByPath, tc.limitedBy)
+			}
+			if info.CPU.Unlimited != tc.unlimited {
+				t.Errorf("CPU.Unlimited = %t, want %t", info.CPU.Unlimited, tc.unlimited)
+			}
+			if info.MemoryLimit != tc.memoryLimit {
+				t.Errorf("MemoryLimit = %d, want %d", info.MemoryLimit, tc.memoryLimit)
+			}
+		})
+	}
+}
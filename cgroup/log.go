@@ -0,0 +1,41 @@
+package cgroup
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"runtime"
+)
+
+// logCPU logs the result of a CPU limit detection to d.Logger, if set.
+func (d *Detector) logCPU(ctx context.Context, limit CPULimit, err error) {
+	if d.Logger == nil {
+		return
+	}
+
+	numCPU := slog.Int("num_cpu", runtime.NumCPU())
+	// An invalid MinGOMAXPROCS or MaxGOMAXPROCS is reported by whatever
+	// applies the adjusted value, so it is logged as 0 here.
+	adjusted, _ := d.Adjust(limit.Effective)
+	switch {
+	case errors.Is(err, ErrNotInCgroup):
+		d.Logger.LogAttrs(ctx, slog.LevelInfo, "not in a cgroup", numCPU)
+	case err != nil:
+		d.Logger.LogAttrs(ctx, slog.LevelWarn, "error detecting cgroup CPU limit", numCPU, slog.Any("error", err))
+	case limit.FromEnv != "":
+		d.Logger.LogAttrs(ctx, slog.LevelInfo, "using CPU limit from environment",
+			numCPU,
+			slog.Float64("effective", limit.Effective),
+			slog.Int("adjusted", adjusted),
+			slog.String("env", limit.FromEnv),
+			slog.Any("cgroup_error", limit.CgroupErr),
+		)
+	default:
+		d.Logger.LogAttrs(ctx, slog.LevelInfo, "detected cgroup CPU limit",
+			numCPU,
+			slog.Float64("effective", limit.Effective),
+			slog.Int("adjusted", adjusted),
+			slog.String("limited_by", limit.LimitedBy),
+		)
+	}
+}
@@ -0,0 +1,151 @@
+package cgroup
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"math"
+	"path"
+)
+
+// MemoryLimit returns the effective memory limit of the current process, or
+// of PID if set, in bytes: the minimum limit found walking from the process's
+// cgroup up to the cgroup root. It returns 0 if the process's cgroup has no
+// memory limit, and ErrNotInCgroup if the process is not in a cgroup.
+func (d *Detector) MemoryLimit() (int64, error) {
+	return d.MemoryLimitContext(context.Background())
+}
+
+// MemoryLimitContext is like MemoryLimit but stops reading cgroup files and
+// returns ctx.Err() once ctx is done.
+func (d *Detector) MemoryLimitContext(ctx context.Context) (int64, error) {
+	fsys, m, cgroups, err := d.resolve(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return d.memoryLimit(fsys, cgroups, m)
+}
+
+// memoryLimit implements MemoryLimitContext for the process whose cgroups are listed
+// in cgroups.
+func (d *Detector) memoryLimit(fsys fs.FS, cgroups procCgroups, m mounts) (int64, error) {
+	limit, err := d.controllerLimit(fsys, cgroups, m, "memory", calculateV1MemoryLimit, calculateV2MemoryLimit)
+	if err != nil {
+		return 0, err
+	}
+	return int64(limit.limit), nil
+}
+
+// MemoryHigh returns the effective memory.high of the current process, or of
+// PID if set, in bytes: the minimum found walking from the process's cgroup
+// up to the cgroup root. Above it the kernel throttles the cgroup and
+// reclaims its memory, well before the OOM killer acts at MemoryLimit. It
+// returns 0 if no memory.high is set, including on cgroup v1, which has no
+// equivalent, and ErrNotInCgroup if the process is not in a cgroup.
+func (d *Detector) MemoryHigh() (int64, error) {
+	return d.MemoryHighContext(context.Background())
+}
+
+// MemoryHighContext is like MemoryHigh but stops reading cgroup files and
+// returns ctx.Err() once ctx is done.
+func (d *Detector) MemoryHighContext(ctx context.Context) (int64, error) {
+	fsys, m, cgroups, err := d.resolve(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return d.memoryHigh(fsys, cgroups, m)
+}
+
+// memoryHigh implements MemoryHighContext for the process whose cgroups are listed
+// in cgroups.
+func (d *Detector) memoryHigh(fsys fs.FS, cgroups procCgroups, m mounts) (int64, error) {
+	limit, err := d.controllerLimit(fsys, cgroups, m, "memory", calculateV1MemoryHigh, calculateV2MemoryHigh)
+	if err != nil {
+		return 0, err
+	}
+	return int64(limit.limit), nil
+}
+
+// SuggestGOMEMLIMIT returns a GOMEMLIMIT that leaves the fraction headroom of
+// the memory limit for memory the Go runtime does not manage, e.g. 0.1 for
+// 10%. The limit is memory.high if it is lower than the hard limit, since
+// reclaim pressure starts there. Headroom must be in [0, 1). It returns 0 if
+// the process's cgroup has no memory limit, and ErrNotInCgroup if the
+// process is not in a cgroup.
+func (d *Detector) SuggestGOMEMLIMIT(headroom float64) (int64, error) {
+	if headroom < 0 || headroom >= 1 {
+		return 0, fmt.Errorf("headroom %v must be at least 0 and less than 1", headroom)
+	}
+	fsys, m, cgroups, err := d.resolve(context.Background())
+	if err != nil {
+		return 0, err
+	}
+	limit, err := d.memoryLimit(fsys, cgroups, m)
+	if err != nil {
+		return 0, err
+	}
+	high, err := d.memoryHigh(fsys, cgroups, m)
+	if err != nil {
+		return 0, err
+	}
+	if high != 0 && (limit == 0 || high < limit) {
+		limit = high
+	}
+	return int64(float64(limit) * (1 - headroom)), nil
+}
+
+// MemoryLimit calls MemoryLimit on a Detector reading from the host.
+func MemoryLimit() (int64, error) {
+	return (&Detector{}).MemoryLimit()
+}
+
+// MemoryLimitContext calls MemoryLimitContext on a Detector reading from the
+// host.
+func MemoryLimitContext(ctx context.Context) (int64, error) {
+	return (&Detector{}).MemoryLimitContext(ctx)
+}
+
+// MemoryHigh calls MemoryHigh on a Detector reading from the host.
+func MemoryHigh() (int64, error) {
+	return (&Detector{}).MemoryHigh()
+}
+
+// MemoryHighContext calls MemoryHighContext on a Detector reading from the
+// host.
+func MemoryHighContext(ctx context.Context) (int64, error) {
+	return (&Detector{}).MemoryHighContext(ctx)
+}
+
+// SuggestGOMEMLIMIT calls SuggestGOMEMLIMIT on a Detector reading from the
+// host.
+func SuggestGOMEMLIMIT(headroom float64) (int64, error) {
+	return (&Detector{}).SuggestGOMEMLIMIT(headroom)
+}
+
+// calculateV1MemoryLimit reads memory.limit_in_bytes for a given cgroup v1 path.
+func calculateV1MemoryLimit(fsys fs.FS, dir string) (float64, error) {
+	limit, err := readIntFromFile(fsys, path.Join(dir, "memory.limit_in_bytes"))
+	if err != nil {
+		return 0, err
+	}
+	if parseUnlimited(limit) {
+		return math.Inf(1), nil
+	}
+	return float64(limit), nil
+}
+
+// calculateV2MemoryLimit reads memory.max for a given cgroup v2 path.
+func calculateV2MemoryLimit(fsys fs.FS, dir string) (float64, error) {
+	return readMaxFile(fsys, path.Join(dir, "memory.max"))
+}
+
+// calculateV1MemoryHigh sets no limit, since cgroup v1 has no memory.high.
+// Its memory.soft_limit_in_bytes only applies under global memory pressure.
+func calculateV1MemoryHigh(fsys fs.FS, dir string) (float64, error) {
+	return math.Inf(1), nil
+}
+
+// calculateV2MemoryHigh reads memory.high for a given cgroup v2 path.
+func calculateV2MemoryHigh(fsys fs.FS, dir string) (float64, error) {
+	return readMaxFile(fsys, path.Join(dir, "memory.high"))
+}
@@ -0,0 +1,220 @@
+package cgroup
+
+import (
+	"bufio"
+	"context"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// procSelfMountinfoPath lists the mounts visible to the current process
+const procSelfMountinfoPath = "proc/self/mountinfo"
+
+// hierarchy is a mounted cgroup hierarchy.
+type hierarchy struct {
+	// mountPoint is where the hierarchy is mounted, relative to the root of
+	// the Detector's filesystem.
+	mountPoint string
+	// root is the cgroup mounted at mountPoint, usually "/". Container
+	// runtimes without cgroup namespaces bind mount the container's own
+	// cgroup instead.
+	root string
+	// v2 is true for the unified hierarchy.
+	v2 bool
+}
+
+// dir returns the directory of the cgroup at cgroupPath, as listed in
+// /proc/self/cgroup.
+func (h hierarchy) dir(cgroupPath string) string {
+	// Inside a cgroup namespace the process's cgroup is the namespace root,
+	// "/", which is mounted at the mount point. A path starting with ".." is
+	// outside the namespace and cannot be seen, so the namespace root is the
+	// nearest visible ancestor. Joining it would escape the mount point.
+	if cgroupPath == "/" || cgroupPath == "/.." || strings.HasPrefix(cgroupPath, "/../") {
+		return h.mountPoint
+	}
+
+	// Clean the rooted path so that ".." components in a crafted
+	// /proc/self/cgroup cannot climb out of the mount point.
+	cgroupPath = path.Join("/", cgroupPath)
+	if h.root != "/" {
+		if rel, ok := strings.CutPrefix(cgroupPath, h.root); ok && (rel == "" || rel[0] == '/') {
+			cgroupPath = rel
+		}
+	}
+	return path.Join(h.mountPoint, cgroupPath)
+}
+
+// mounts records where cgroup hierarchies are mounted.
+type mounts struct {
+	// fromMountinfo is false if mountinfo could not be parsed and the
+	// default paths are assumed instead.
+	fromMountinfo bool
+	v2            *hierarchy
+	v1            map[string]hierarchy
+
+	// v2Root and v1Root override where the hierarchies are mounted if not
+	// empty. v1Root contains a directory per controller.
+	v2Root string
+	v1Root string
+}
+
+// findMounts parses /proc/self/mountinfo to locate the cgroup hierarchies.
+// If it cannot be read or lists no cgroup mounts, the default layout under
+// /sys/fs/cgroup is assumed. The Detector's CgroupV2Path and CgroupV1Path
+// take precedence over both.
+func (d *Detector) findMounts(fsys fs.FS) mounts {
+	m, err := parseMountinfo(fsys)
+	if err != nil || (m.v2 == nil && len(m.v1) == 0) {
+		m = mounts{}
+	}
+	m.v2Root = d.CgroupV2Path
+	m.v1Root = d.CgroupV1Path
+	return m
+}
+
+// parseMountinfo parses the cgroup and cgroup2 mounts from
+// /proc/self/mountinfo.
+func parseMountinfo(fsys fs.FS) (mounts, error) {
+	file, err := fsys.Open(procSelfMountinfoPath)
+	if err != nil {
+		return mounts{}, err
+	}
+	defer file.Close()
+
+	m := mounts{
+		fromMountinfo: true,
+		v1:            map[string]hierarchy{},
+	}
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// The format is:
+		// "id parent major:minor root mountpoint options [optional...] - fstype source superoptions"
+		fields := strings.Fields(scanner.Text())
+		sep := -1
+		for i, f := range fields {
+			if f == "-" {
+				sep = i
+				break
+			}
+		}
+		if sep < 5 || len(fields) < sep+4 {
+			continue
+		}
+
+		h := hierarchy{
+			mountPoint: strings.TrimPrefix(path.Clean(unescapeMountinfo(fields[4])), "/"),
+			root:       unescapeMountinfo(fields[3]),
+		}
+		if h.mountPoint == "" {
+			h.mountPoint = "."
+		}
+
+		switch fields[sep+1] {
+		case "cgroup2":
+			if m.v2 == nil {
+				h.v2 = true
+				m.v2 = &h
+			}
+		case "cgroup":
+			// v1 superoptions list the controllers, e.g. "rw,cpu,cpuacct".
+			for _, opt := range strings.Split(fields[sep+3], ",") {
+				if _, ok := m.v1[opt]; !ok {
+					m.v1[opt] = h
+				}
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return mounts{}, err
+	}
+
+	return m, nil
+}
+
+// unescapeMountinfo reverses the octal escaping the kernel applies to
+// whitespace and backslashes in mountinfo paths.
+var unescapeMountinfo = strings.NewReplacer(`\040`, " ", `\011`, "\t", `\012`, "\n", `\134`, `\`).Replace
+
+// cgroupV2 returns the cgroup v2 hierarchy, if one is mounted.
+func (m mounts) cgroupV2(fsys fs.FS) (hierarchy, bool) {
+	if m.v2Root != "" {
+		return hierarchy{mountPoint: m.v2Root, root: "/", v2: true}, true
+	}
+	if m.fromMountinfo {
+		if m.v2 == nil {
+			return hierarchy{}, false
+		}
+		return *m.v2, true
+	}
+
+	// The existence of "cgroup.controllers" is a good indicator of a v2 hierarchy.
+	if _, err := fs.Stat(fsys, path.Join(cgroupV2Path, "cgroup.controllers")); err != nil {
+		return hierarchy{}, false
+	}
+	return hierarchy{mountPoint: cgroupV2Path, root: "/", v2: true}, true
+}
+
+// cgroupV1 returns the cgroup v1 hierarchy containing controller, if one is
+// mounted.
+func (m mounts) cgroupV1(fsys fs.FS, controller string) (hierarchy, bool) {
+	root := m.v1Root
+	if root == "" {
+		if m.fromMountinfo {
+			h, ok := m.v1[controller]
+			return h, ok
+		}
+		root = cgroupV2Path
+	}
+
+	mountPoint := path.Join(root, controller)
+	if _, err := fs.Stat(fsys, mountPoint); err != nil {
+		return hierarchy{}, false
+	}
+	return hierarchy{mountPoint: mountPoint, root: "/"}, true
+}
+
+// version returns "v2" if only the unified hierarchy is mounted, "v1" if
+// only v1 hierarchies are, "hybrid" if both are, or "none".
+func (m mounts) version(fsys fs.FS) string {
+	_, v2 := m.cgroupV2(fsys)
+	v1 := len(m.v1) > 0
+	if m.v1Root != "" || !m.fromMountinfo {
+		_, v1 = m.cgroupV1(fsys, "cpu")
+	}
+
+	switch {
+	case v1 && v2:
+		return "hybrid"
+	case v2:
+		return "v2"
+	case v1:
+		return "v1"
+	default:
+		return "none"
+	}
+}
+
+// CgroupVersion returns which cgroup hierarchies are mounted: "v1", "v2",
+// "hybrid" if both are, e.g. with systemd's hybrid layout, or "none".
+func (d *Detector) CgroupVersion() string {
+	fsys := d.fsys(context.Background())
+	return d.findMounts(fsys).version(fsys)
+}
+
+// hierarchiesFor returns the hierarchies to read controller's files from:
+// the v2 hierarchy and the v1 hierarchy containing controller. Hybrid hosts
+// mount both, and the controller may be attached to either.
+func (m mounts) hierarchiesFor(fsys fs.FS, controller string) []hierarchy {
+	var hs []hierarchy
+	if h, ok := m.cgroupV2(fsys); ok {
+		hs = append(hs, h)
+	}
+	if h, ok := m.cgroupV1(fsys, controller); ok {
+		hs = append(hs, h)
+	}
+	return hs
+}
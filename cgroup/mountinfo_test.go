@@ -0,0 +1,27 @@
+package cgroup
+
+import "testing"
+
+func TestHierarchyDirStaysInMount(t *testing.T) {
+	root := hierarchy{mountPoint: "sys/fs/cgroup", root: "/", v2: true}
+	bind := hierarchy{mountPoint: "sys/fs/cgroup", root: "/docker/abc", v2: true}
+	cases := []struct {
+		h          hierarchy
+		cgroupPath string
+		want       string
+	}{
+		{root, "/", "sys/fs/cgroup"},
+		{root, "/kube/pod", "sys/fs/cgroup/kube/pod"},
+		{root, "/..", "sys/fs/cgroup"},
+		{root, "/../../etc/passwd", "sys/fs/cgroup"},
+		{root, "/kube/../../../../etc/passwd", "sys/fs/cgroup/etc/passwd"},
+		{root, "kube/../../etc", "sys/fs/cgroup/etc"},
+		{bind, "/docker/abc", "sys/fs/cgroup"},
+		{bind, "/docker/abc/../../../etc", "sys/fs/cgroup/etc"},
+	}
+	for _, tc := range cases {
+		if got := tc.h.dir(tc.cgroupPath); got != tc.want {
+			t.Errorf("dir(%q) with root %q = %q, want %q", tc.cgroupPath, tc.h.root, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package cgroup
+
+import (
+	"context"
+	"io/fs"
+	"path"
+)
+
+// PidsLimit returns the maximum number of tasks (processes and threads) the
+// current process's cgroup, or PID's if set, may contain: the minimum
+// pids.max found walking from the process's cgroup up to the cgroup root. Go
+// programs that hit it fail to create OS threads with EAGAIN. It returns 0
+// if there is no limit, and ErrNotInCgroup if the process is not in a cgroup.
+func (d *Detector) PidsLimit() (int64, error) {
+	return d.PidsLimitContext(context.Background())
+}
+
+// PidsLimitContext is like PidsLimit but stops reading cgroup files and
+// returns ctx.Err() once ctx is done.
+func (d *Detector) PidsLimitContext(ctx context.Context) (int64, error) {
+	fsys, m, cgroups, err := d.resolve(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return d.pidsLimit(fsys, cgroups, m)
+}
+
+// pidsLimit implements PidsLimitContext for the process whose cgroups are
+// listed in cgroups.
+func (d *Detector) pidsLimit(fsys fs.FS, cgroups procCgroups, m mounts) (int64, error) {
+	limit, err := d.controllerLimit(fsys, cgroups, m, "pids", calculatePidsLimit, calculatePidsLimit)
+	if err != nil {
+		return 0, err
+	}
+	return int64(limit.limit), nil
+}
+
+// PidsLimit calls PidsLimit on a Detector reading from the host.
+func PidsLimit() (int64, error) {
+	return (&Detector{}).PidsLimit()
+}
+
+// PidsLimitContext calls PidsLimitContext on a Detector reading from the
+// host.
+func PidsLimitContext(ctx context.Context) (int64, error) {
+	return (&Detector{}).PidsLimitContext(ctx)
+}
+
+// calculatePidsLimit reads pids.max for a given cgroup path. The file has the
+// same format in v1 and v2.
+func calculatePidsLimit(fsys fs.FS, dir string) (float64, error) {
+	return readMaxFile(fsys, path.Join(dir, "pids.max"))
+}
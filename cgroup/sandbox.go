@@ -0,0 +1,22 @@
+package cgroup
+
+import (
+	"context"
+	"io/fs"
+	"strings"
+)
+
+// gVisorVersion is the fixed /proc/version gVisor's Sentry reports, which
+// has not changed since gVisor was released.
+const gVisorVersion = "Linux version 4.4.0 #1 SMP Sun Jan 10 15:06:54 PST 2016"
+
+// GVisor reports whether the process is running under gVisor (runsc). Its
+// cgroup filesystem is emulated, so limits read from it may not reflect how
+// the sandbox actually enforces CPU and memory.
+func (d *Detector) GVisor() bool {
+	content, err := fs.ReadFile(d.fsys(context.Background()), "proc/version")
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(string(content), gVisorVersion)
+}
@@ -0,0 +1,22 @@
+package cgroup
+
+import "strings"
+
+// systemdUnitSuffixes are the systemd unit types that own cgroups.
+var systemdUnitSuffixes = []string{".service", ".scope", ".slice"}
+
+// SystemdUnit returns the systemd unit that owns the cgroup directory dir,
+// such as "myservice.service" for "sys/fs/cgroup/system.slice/myservice.service":
+// the innermost path component named after a service, scope, or slice. It
+// returns false if no component is a systemd unit.
+func SystemdUnit(dir string) (string, bool) {
+	components := strings.Split(dir, "/")
+	for i := len(components) - 1; i >= 0; i-- {
+		for _, suffix := range systemdUnitSuffixes {
+			if c := components[i]; len(c) > len(suffix) && strings.HasSuffix(c, suffix) {
+				return c, true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,133 @@
+package cgroup
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ThrottleStats is the CFS bandwidth control statistics of a cgroup, from its
+// cpu.stat file.
+type ThrottleStats struct {
+	// Periods is the number of enforcement periods that have elapsed in
+	// which the cgroup was runnable.
+	Periods int64
+	// ThrottledPeriods is the number of those periods in which the cgroup
+	// used its whole quota and was throttled.
+	ThrottledPeriods int64
+	// ThrottledTime is the total time the cgroup's tasks spent throttled.
+	ThrottledTime time.Duration
+}
+
+// Throttling returns the throttling statistics of the current process's own
+// cgroup, or of PID if set. It returns ErrNotInCgroup if the process is not
+// in a cgroup, and ErrCgroupUnsupported if no cpu.stat reports throttling,
+// e.g. when the cpu controller is not enabled.
+func (d *Detector) Throttling() (ThrottleStats, error) {
+	return d.ThrottlingContext(context.Background())
+}
+
+// ThrottlingContext is like Throttling but stops reading cgroup files and
+// returns ctx.Err() once ctx is done.
+func (d *Detector) ThrottlingContext(ctx context.Context) (ThrottleStats, error) {
+	fsys, m, cgroups, err := d.resolve(ctx)
+	if err != nil {
+		return ThrottleStats{}, err
+	}
+	return throttling(fsys, cgroups, m)
+}
+
+// throttling implements ThrottlingContext for the process whose cgroups are
+// listed in cgroups.
+func throttling(fsys fs.FS, cgroups procCgroups, m mounts) (ThrottleStats, error) {
+	hs := m.hierarchiesFor(fsys, "cpu")
+	if len(hs) == 0 {
+		return ThrottleStats{}, ErrNotInCgroup
+	}
+
+	for _, h := range hs {
+		controller := "cpu"
+		if h.v2 {
+			controller = ""
+		}
+		cgroupPath, err := cgroups.path(controller)
+		if errors.Is(err, ErrCgroupUnsupported) && len(hs) > 1 {
+			continue
+		}
+		if err != nil {
+			return ThrottleStats{}, err
+		}
+
+		t, ok, err := readCPUStat(fsys, path.Join(h.dir(cgroupPath), "cpu.stat"), h.v2)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return ThrottleStats{}, err
+		}
+		if ok {
+			return t, nil
+		}
+	}
+
+	return ThrottleStats{}, fmt.Errorf("%w: no cpu.stat reports throttling", ErrCgroupUnsupported)
+}
+
+// Throttling calls Throttling on a Detector reading from the host.
+func Throttling() (ThrottleStats, error) {
+	return (&Detector{}).Throttling()
+}
+
+// ThrottlingContext calls ThrottlingContext on a Detector reading from the
+// host.
+func ThrottlingContext(ctx context.Context) (ThrottleStats, error) {
+	return (&Detector{}).ThrottlingContext(ctx)
+}
+
+// readCPUStat parses a cpu.stat file. The v1 file reports throttled_time in
+// nanoseconds, while v2 reports throttled_usec. It returns false if the file
+// has no throttling statistics.
+func readCPUStat(fsys fs.FS, filePath string, v2 bool) (ThrottleStats, bool, error) {
+	file, err := fsys.Open(filePath)
+	if err != nil {
+		return ThrottleStats{}, false, err
+	}
+	defer file.Close()
+
+	var t ThrottleStats
+	found := false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, val, ok := strings.Cut(scanner.Text(), " ")
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseInt(strings.TrimSpace(val), 10, 64)
+		if err != nil {
+			return ThrottleStats{}, false, &ParseError{File: filePath, Content: scanner.Text(), Err: err}
+		}
+
+		switch {
+		case key == "nr_periods":
+			t.Periods = n
+		case key == "nr_throttled":
+			t.ThrottledPeriods = n
+			found = true
+		case key == "throttled_usec" && v2:
+			t.ThrottledTime = time.Duration(n) * time.Microsecond
+		case key == "throttled_time" && !v2:
+			t.ThrottledTime = time.Duration(n)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return ThrottleStats{}, false, err
+	}
+
+	return t, found, nil
+}
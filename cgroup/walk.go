@@ -0,0 +1,287 @@
+package cgroup
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"path"
+	"strings"
+)
+
+// limitFunc calculates the limit set at the cgroup directory dir. It returns
+// +Inf if the limit is explicitly unlimited.
+type limitFunc func(fsys fs.FS, dir string) (float64, error)
+
+// limitAt is a limit and the cgroup directory that set it.
+type limitAt struct {
+	// limit is 0 if no level sets a limit.
+	limit float64
+	// path is relative to the root of the Detector's filesystem, and empty
+	// if limit is 0.
+	path string
+	// denied lists the directories whose limit could not be read for lack
+	// of permission, so limit may be incomplete.
+	denied []string
+	// failed holds the other errors reading limit files that exist, e.g.
+	// malformed contents. Their levels are skipped.
+	failed []error
+}
+
+// walkMode selects which levels of a hierarchy determine a limit.
+type walkMode int
+
+const (
+	// walkAll takes the minimum from the process's cgroup up to the root.
+	walkAll walkMode = iota
+	// walkLeaf only reads the process's own cgroup.
+	walkLeaf
+	// walkNearest stops at the first level up from the process's cgroup
+	// that sets a limit.
+	walkNearest
+)
+
+// walkMode returns the levels the Detector reads limits from.
+func (d *Detector) walkMode() walkMode {
+	switch {
+	case d.LeafOnly:
+		return walkLeaf
+	case d.Nearest:
+		return walkNearest
+	default:
+		return walkAll
+	}
+}
+
+// controllerLimit returns the minimum limit of controller found walking from
+// the process's cgroup, as listed in cgroups, up to the root of each
+// hierarchy in m the controller may be attached to, or the levels
+// d.walkMode selects, calculating the limit at each level with calcV1 or
+// calcV2. It returns ErrNotInCgroup if no such hierarchy is mounted.
+func (d *Detector) controllerLimit(fsys fs.FS, cgroups procCgroups, m mounts, controller string, calcV1, calcV2 limitFunc) (limitAt, error) {
+	hs := m.hierarchiesFor(fsys, controller)
+	if len(hs) == 0 {
+		return limitAt{}, ErrNotInCgroup
+	}
+
+	limit, err := getMinLimit(fsys, cgroups, hs, controller, d.walkMode(), calcV1, calcV2)
+	if err != nil {
+		return limitAt{}, err
+	}
+	if err := contextErr(fsys); err != nil {
+		return limitAt{}, err
+	}
+	if err := checkProcess(fsys, d.PID); err != nil {
+		return limitAt{}, err
+	}
+	return limit, nil
+}
+
+// getCgroupLimit walks the hierarchy h from the process's cgroup for
+// controller, as listed in cgroups, calculating the limit at each level with
+// calcFunc.
+func getCgroupLimit(fsys fs.FS, cgroups procCgroups, h hierarchy, controller string, mode walkMode, calcFunc limitFunc) (limitAt, error) {
+	version := "v1"
+	if h.v2 {
+		// For v2, the controller name is not prefixed in /proc/self/cgroup
+		controller, version = "", "v2"
+	}
+
+	cgroupPath, err := cgroups.path(controller)
+	if err != nil {
+		return limitAt{}, fmt.Errorf("failed to get cgroup %s path: %w", version, err)
+	}
+
+	// The full path to the process's specific cgroup directory.
+	fullPath := h.dir(cgroupPath)
+	rootPath := h.mountPoint
+	if mode == walkLeaf {
+		rootPath = fullPath
+	}
+	return walkHierarchy(fsys, fullPath, calcFunc, rootPath, mode == walkNearest)
+}
+
+// getMinLimit returns the minimum limit across the hierarchies hs, using
+// calcV1 or calcV2 to calculate the limit at each level. It returns 0 if no
+// hierarchy sets a limit.
+func getMinLimit(fsys fs.FS, cgroups procCgroups, hs []hierarchy, controller string, mode walkMode, calcV1, calcV2 limitFunc) (limitAt, error) {
+	var minLimit limitAt
+	var denied []string
+	var failed []error
+	for _, h := range hs {
+		calcFunc := calcV1
+		if h.v2 {
+			calcFunc = calcV2
+		}
+
+		limit, err := getCgroupLimit(fsys, cgroups, h, controller, mode, calcFunc)
+		if errors.Is(err, ErrCgroupUnsupported) && len(hs) > 1 {
+			// On hybrid hosts the process may not be attached to every
+			// hierarchy.
+			continue
+		}
+		if err != nil {
+			return limitAt{}, err
+		}
+		denied = append(denied, limit.denied...)
+		failed = append(failed, limit.failed...)
+		minLimit = minLimitAt(minLimit, limit)
+	}
+
+	minLimit.denied = denied
+	minLimit.failed = failed
+	return minLimit, nil
+}
+
+// minLimitAt returns the smaller of two limits, where 0 means unlimited.
+func minLimitAt(a, b limitAt) limitAt {
+	if a.limit == 0 || (b.limit != 0 && b.limit < a.limit) {
+		return b
+	}
+	return a
+}
+
+// procCgroups is a parsed /proc/<pid>/cgroup.
+type procCgroups struct {
+	// file is the path it was parsed from, for errors.
+	file string
+	// paths maps each v1 controller, and "" for the v2 hierarchy, to the
+	// process's cgroup path in that hierarchy.
+	paths map[string]string
+}
+
+// parseProcessCgroups parses /proc/<pid>/cgroup once so that the paths of
+// several controllers can be looked up. A pid of 0 reads /proc/self/cgroup.
+func parseProcessCgroups(fsys fs.FS, pid int) (procCgroups, error) {
+	cgroups := procCgroups{file: procCgroupPath(pid), paths: map[string]string{}}
+	file, err := fsys.Open(cgroups.file)
+	if errors.Is(err, fs.ErrNotExist) {
+		if pid != 0 {
+			return procCgroups{}, fmt.Errorf("%w: pid %d", ErrNoProcess, pid)
+		}
+		return procCgroups{}, fmt.Errorf("%w: %w", ErrNotInCgroup, err)
+	}
+	if err != nil {
+		return procCgroups{}, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		// Paths may contain colons, so only split off the first two fields.
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+
+		// For cgroup v2, the format is "0::path", and there must be exactly
+		// one such line.
+		if parts[0] == "0" && parts[1] == "" {
+			if _, ok := cgroups.paths[""]; ok {
+				return procCgroups{}, &ParseError{File: cgroups.file, Content: line, Err: errors.New("multiple cgroup v2 entries")}
+			}
+			cgroups.paths[""] = parts[2]
+			continue
+		}
+
+		// For cgroup v1, the format is "id:controllers:path", where
+		// controllers is a comma-separated list such as "cpu,cpuacct".
+		// Each is recorded exactly so that "cpu" does not match "cpuset".
+		for _, controller := range strings.Split(parts[1], ",") {
+			if _, ok := cgroups.paths[controller]; !ok && controller != "" {
+				cgroups.paths[controller] = parts[2]
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return procCgroups{}, err
+	}
+	return cgroups, nil
+}
+
+// path returns the process's cgroup path for a v1 controller, or for the v2
+// hierarchy if controller is "".
+func (c procCgroups) path(controller string) (string, error) {
+	p, ok := c.paths[controller]
+	if !ok {
+		return "", fmt.Errorf("%w: cgroup path for controller '%s' not found in /%s", ErrCgroupUnsupported, controller, c.file)
+	}
+	return p, nil
+}
+
+// getProcessCgroupPath parses /proc/<pid>/cgroup to find the path for a
+// specific controller. A pid of 0 reads /proc/self/cgroup.
+func getProcessCgroupPath(fsys fs.FS, pid int, controller string) (string, error) {
+	cgroups, err := parseProcessCgroups(fsys, pid)
+	if err != nil {
+		return "", err
+	}
+	return cgroups.path(controller)
+}
+
+// walkHierarchy traverses up the cgroup directory tree from a starting path
+// up to a root path, calculating the CPU limit at each level.
+// It returns the minimum limit found and the directory that set it. If
+// nearest is true it stops at the first level that sets a limit instead.
+func walkHierarchy(fsys fs.FS, startPath string, calcFunc limitFunc, rootPath string, nearest bool) (limitAt, error) {
+	minLimit := math.Inf(1) // Initialize with positive infinity
+	minPath := ""
+	var denied []string
+	var failed []error
+	currentPath := startPath
+
+	for {
+		// Check for cancellation between levels, since the errors from
+		// calcFunc are not returned.
+		if err := contextErr(fsys); err != nil {
+			return limitAt{}, err
+		}
+
+		limit, err := calcFunc(fsys, currentPath)
+		if errors.Is(err, fs.ErrNotExist) {
+			// It's possible for some levels not to have limits set, e.g.
+			// the v2 root never has a cpu.max, so we don't error out.
+		} else if errors.Is(err, fs.ErrPermission) {
+			// Unlike a missing file, an unreadable one may hide a limit.
+			denied = append(denied, currentPath)
+		} else if errors.Is(err, ErrTornRead) {
+			return limitAt{}, err
+		} else if err != nil {
+			// A file that exists but cannot be parsed may also hide a
+			// limit, so skip the level but report it.
+			failed = append(failed, err)
+		} else if limit < minLimit {
+			// Update the minimum limit if the current one is smaller.
+			minLimit = limit
+			minPath = currentPath
+		}
+
+		if nearest && minPath != "" {
+			break
+		}
+
+		// Stop if we have reached the root of the cgroup filesystem. The
+		// root is read exactly once, including when the process is in the
+		// root cgroup and startPath is rootPath.
+		if currentPath == rootPath {
+			break
+		}
+
+		// Move to the parent directory, unless that would leave rootPath
+		// or make no progress, as path.Dir does at "." and "/".
+		parent := path.Dir(currentPath)
+		if parent == currentPath || !withinDir(parent, rootPath) {
+			break
+		}
+		currentPath = parent
+	}
+
+	if math.IsInf(minLimit, 1) {
+		return limitAt{denied: denied, failed: failed}, nil
+	}
+
+	return limitAt{limit: minLimit, path: minPath, denied: denied, failed: failed}, nil
+}
@@ -0,0 +1,135 @@
+package cgroup
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// deepCgroup is a kubepods cgroup 7 levels below the v2 root, as on nodes
+// with systemd slices nested per QoS class and pod.
+var deepCgroup = []string{
+	"kubepods.slice",
+	"kubepods-burstable.slice",
+	"kubepods-burstable-pod1234.slice",
+	"cri-containerd-abcdef.scope",
+	"app.slice",
+	"worker.slice",
+	"task.scope",
+}
+
+// deepFS returns a cgroup v2 tree with a cpu.max at every level of
+// deepCgroup, and the path of the deepest level.
+func deepFS() (fstest.MapFS, string) {
+	fsys := fstest.MapFS{
+		"sys/fs/cgroup/cgroup.controllers": {Data: []byte("cpu cpuset memory pids\n")},
+	}
+	dir := cgroupV2Path
+	for i, name := range deepCgroup {
+		dir = path.Join(dir, name)
+		// Tighten the quota by a core at each level.
+		quota := (len(deepCgroup) + 1 - i) * 100000
+		fsys[path.Join(dir, "cpu.max")] = &fstest.MapFile{Data: []byte(strconv.Itoa(quota) + " 100000\n")}
+	}
+	return fsys, dir
+}
+
+func BenchmarkWalkHierarchy(b *testing.B) {
+	fsys, dir := deepFS()
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := walkHierarchy(fsys, dir, calculateV2CPUQuota, cgroupV2Path, false); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestWalkReadsEachLevelOnce(t *testing.T) {
+	v2 := hierarchy{mountPoint: cgroupV2Path, root: "/", v2: true}
+	v1 := hierarchy{mountPoint: "sys/fs/cgroup/cpu", root: "/"}
+	cases := []struct {
+		name       string
+		h          hierarchy
+		procCgroup string
+		want       []string
+	}{
+		{"v2 root cgroup", v2, "0::/\n", []string{"sys/fs/cgroup"}},
+		{"v1 root cgroup", v1, "4:cpu,cpuacct:/\n", []string{"sys/fs/cgroup/cpu"}},
+		{"v2 nested", v2, "0::/a/b\n", []string{"sys/fs/cgroup/a/b", "sys/fs/cgroup/a", "sys/fs/cgroup"}},
+		{"v1 nested", v1, "4:cpu,cpuacct:/a\n", []string{"sys/fs/cgroup/cpu/a", "sys/fs/cgroup/cpu"}},
+		{"trailing slash", v2, "0::/a/\n", []string{"sys/fs/cgroup/a", "sys/fs/cgroup"}},
+		{"outside namespace", v2, "0::/../a\n", []string{"sys/fs/cgroup"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			fsys := fstest.MapFS{"proc/self/cgroup": {Data: []byte(tc.procCgroup)}}
+			cgroups, err := parseProcessCgroups(fsys, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var visited []string
+			visit := func(fsys fs.FS, dir string) (float64, error) {
+				visited = append(visited, dir)
+				return 0, fs.ErrNotExist
+			}
+			if _, err := getCgroupLimit(fsys, cgroups, tc.h, "cpu", walkAll, visit); err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(visited, tc.want) {
+				t.Errorf("visited %q, want %q", visited, tc.want)
+			}
+		})
+	}
+}
+
+func FuzzParseProcessCgroups(f *testing.F) {
+	for _, seed := range []string{
+		"0::/kube\n",
+		"0::/\n",
+		"0::/a\n0::/b\n",
+		"4:cpu,cpuacct:/app\n0::/app\n",
+		"3:cpuset:/a\n4:cpu:/b\n",
+		"4:cpu,,cpuacct:/app\n",
+		"4::/app\n",
+		"4:cpu\n",
+		"garbage\n",
+		"0::/pod:with:colons\n",
+		"1:name=systemd:/user.slice\n",
+		"",
+		"\n\n",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, content string) {
+		fsys := fstest.MapFS{"proc/self/cgroup": {Data: []byte(content)}}
+		cgroups, err := parseProcessCgroups(fsys, 0)
+		if err != nil {
+			var perr *ParseError
+			if !errors.As(err, &perr) && !errors.Is(err, bufio.ErrTooLong) {
+				t.Fatalf("parseProcessCgroups(%q) = %v, want a ParseError", content, err)
+			}
+			return
+		}
+		for controller, p := range cgroups.paths {
+			if strings.Contains(controller, ",") || strings.Contains(p, "\n") {
+				t.Errorf("parseProcessCgroups(%q) parsed %q: %q", content, controller, p)
+			}
+			got, err := getProcessCgroupPath(fsys, 0, controller)
+			if err != nil || got != p {
+				t.Errorf("getProcessCgroupPath(%q) = %q, %v, want %q", controller, got, err, p)
+			}
+		}
+		if _, ok := cgroups.paths[""]; !ok {
+			if _, err := getProcessCgroupPath(fsys, 0, ""); !errors.Is(err, ErrCgroupUnsupported) {
+				t.Errorf("getProcessCgroupPath without a v2 entry = %v, want ErrCgroupUnsupported", err)
+			}
+		}
+	})
+}
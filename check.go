@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+)
+
+// recommended returns the GOMAXPROCS goplay recommends from r: the adjusted
+// value, or runtime.NumCPU() when there is no cgroup limit.
+func recommended(r report) int {
+	if r.CgroupAdjusted != nil {
+		return *r.CgroupAdjusted
+	}
+	return runtime.NumCPU()
+}
+
+// check records in r whether the runtime's current GOMAXPROCS matches the
+// recommended value.
+func check(r *report) {
+	procs := runtime.GOMAXPROCS(-1)
+	want := recommended(*r)
+	match := procs == want
+	r.CheckRuntime = &procs
+	r.CheckRecommended = &want
+	r.CheckMatch = &match
+}
+
+// printCheck prints the result recorded by check.
+func printCheck(w io.Writer, r report) {
+	if *r.CheckMatch {
+		fmt.Fprintf(w, "check:                   MATCH runtime.GOMAXPROCS(-1) %d == recommended %d\n", *r.CheckRuntime, *r.CheckRecommended)
+	} else {
+		fmt.Fprintf(w, "check:                   MISMATCH runtime.GOMAXPROCS(-1) %d != recommended %d\n", *r.CheckRuntime, *r.CheckRecommended)
+	}
+}
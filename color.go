@@ -0,0 +1,37 @@
+package main
+
+import (
+	"io"
+	"os"
+)
+
+// ANSI escape sequences used to highlight text output on a terminal.
+const (
+	ansiRed   = "\x1b[31m"
+	ansiGreen = "\x1b[32m"
+	ansiReset = "\x1b[0m"
+)
+
+// colorer highlights text with ANSI colors if true and leaves it unchanged
+// otherwise.
+type colorer bool
+
+// useColor reports whether text written to w should be colored: only if w
+// is a terminal and $NO_COLOR is unset or empty, per https://no-color.org.
+func useColor(w io.Writer) colorer {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	f, ok := w.(*os.File)
+	return colorer(ok && isTerminal(f))
+}
+
+func (c colorer) red(s string) string   { return c.wrap(ansiRed, s) }
+func (c colorer) green(s string) string { return c.wrap(ansiGreen, s) }
+
+func (c colorer) wrap(code, s string) string {
+	if !c {
+		return s
+	}
+	return code + s + ansiReset
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"runtime"
+)
+
+// automaxprocs returns the GOMAXPROCS go.uber.org/automaxprocs (v1.6) would
+// choose from r, and why. Unlike goplay it respects a valid $GOMAXPROCS, only
+// reads the quota of the process's own cgroup rather than walking up the
+// hierarchy, rounds down, clamps to a minimum of 1 rather than 2, and leaves
+// GOMAXPROCS unchanged when there is no quota.
+func automaxprocs(r report) (int, string) {
+	if r.AdjustedSource != nil && *r.AdjustedSource == "env" {
+		return *r.CgroupAdjusted, "respects $GOMAXPROCS"
+	}
+	if r.CgroupLeaf == nil {
+		return r.RuntimeGOMAXPROCS, "no quota on own cgroup, leaves GOMAXPROCS unchanged"
+	}
+	procs := int(math.Floor(*r.CgroupLeaf))
+	if procs < 1 {
+		return 1, fmt.Sprintf("floor of own cgroup quota %s, raised to minimum of 1", formatCPUs(*r.CgroupLeaf))
+	}
+	return procs, fmt.Sprintf("floor of own cgroup quota %s", formatCPUs(*r.CgroupLeaf))
+}
+
+// compare records what automaxprocs would choose in r.
+func compare(r *report) {
+	procs, reason := automaxprocs(*r)
+	r.Automaxprocs = &procs
+	r.AutomaxprocsReason = &reason
+}
+
+// printCompare prints the automaxprocs comparison recorded by compare.
+func printCompare(w io.Writer, r report) {
+	goplay, reason := runtime.NumCPU(), "no cgroup limit, leaves runtime.NumCPU()"
+	if r.CgroupAdjusted != nil {
+		goplay = *r.CgroupAdjusted
+		switch *r.AdjustedSource {
+		case "cgroup":
+			reason = fmt.Sprintf("from hierarchy minimum %s", formatCPUs(*r.CgroupEffective))
+		case "cpu-limit-env":
+			reason = fmt.Sprintf("from %s %s", *r.LimitedBy, formatCPUs(*r.CgroupEffective))
+		case "numcpu":
+			reason = "no cgroup limit, falls back to runtime.NumCPU()"
+		case "proposal":
+			reason = "from the Go 1.25 runtime algorithm"
+		default:
+			reason = "from $GOMAXPROCS"
+		}
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "automaxprocs would set:  %d (%s)\n", *r.Automaxprocs, *r.AutomaxprocsReason)
+	fmt.Fprintf(w, "goplay recommends:       %d (%s)\n", goplay, reason)
+	if diff := goplay - *r.Automaxprocs; diff != 0 {
+		fmt.Fprintf(w, "divergence:              %+d\n", diff)
+	} else {
+		fmt.Fprintln(w, "divergence:              none")
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/schmichael/goplay/cgroup"
+)
+
+// controllers are the resources -controller can report on their own.
+var controllers = []string{"cpu", "memory", "pids"}
+
+// printController prints only the limit d detects for controller, without
+// the rest of the report, e.g. for a script checking memory alone: the
+// effective CPU limit in CPUs, the memory limit in bytes, or the maximum
+// number of tasks, or "unlimited" if there is none or the process is not in
+// a cgroup. Errors go to stderr. It returns the exit code.
+func printController(w io.Writer, d *cgroup.Detector, controller string) int {
+	var limit string
+	var err error
+	switch controller {
+	case "cpu":
+		var cpus float64
+		if cpus, err = d.EffectiveCPULimit(); cpus != 0 {
+			limit = formatCPUs(cpus)
+		}
+	case "memory":
+		var bytes int64
+		if bytes, err = d.MemoryLimit(); bytes != 0 {
+			limit = strconv.FormatInt(bytes, 10)
+		}
+	case "pids":
+		var tasks int64
+		if tasks, err = d.PidsLimit(); tasks != 0 {
+			limit = strconv.FormatInt(tasks, 10)
+		}
+	}
+	if err != nil && !errors.Is(err, cgroup.ErrNotInCgroup) {
+		fmt.Fprintln(os.Stderr, describeError(err))
+		return exitError
+	}
+	if limit == "" {
+		limit = "unlimited"
+	}
+	fmt.Fprintln(w, limit)
+	return exitOK
+}
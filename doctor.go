@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+
+	"github.com/schmichael/goplay/cgroup"
+)
+
+// Doctor check results. Only fail makes doctor exit nonzero.
+const (
+	pass = "PASS"
+	warn = "WARN"
+	fail = "FAIL"
+)
+
+// doctorCheck is the result of one sanity check.
+type doctorCheck struct {
+	status string
+	name   string
+	detail string
+}
+
+// doctor runs sanity checks of the environment Go schedules in, prints a
+// checklist, and returns exitError if any check failed.
+func doctor(w io.Writer, d *cgroup.Detector) int {
+	checks := doctorChecks(d)
+	code := exitOK
+	for _, c := range checks {
+		fmt.Fprintf(w, "%s  %-24s %s\n", c.status, c.name+":", c.detail)
+		if c.status == fail {
+			code = exitError
+		}
+	}
+	return code
+}
+
+// doctorChecks runs the checks printed by doctor.
+func doctorChecks(d *cgroup.Detector) []doctorCheck {
+	var checks []doctorCheck
+	check := func(status, name, format string, args ...any) {
+		checks = append(checks, doctorCheck{status: status, name: name, detail: fmt.Sprintf(format, args...)})
+	}
+
+	switch v := d.CgroupVersion(); v {
+	case "none":
+		check(warn, "cgroup mounts", "no cgroup hierarchy mounted, limits cannot be detected")
+	default:
+		check(pass, "cgroup mounts", "cgroup %s", v)
+	}
+
+	procCgroup := "/proc/self/cgroup"
+	if d.PID != 0 {
+		procCgroup = fmt.Sprintf("/proc/%d/cgroup", d.PID)
+	}
+	if p, err := d.CgroupPath(); errors.Is(err, cgroup.ErrNotInCgroup) {
+		check(warn, procCgroup, "not in a cgroup")
+	} else if err != nil {
+		check(fail, procCgroup, "%v", err)
+	} else {
+		check(pass, procCgroup, "cgroup %s", p)
+	}
+
+	var effective float64
+	if cpu, err := d.CPU(); errors.Is(err, cgroup.ErrNotInCgroup) {
+		check(warn, "cgroup cpu files", "not in a cgroup, so no CPU limit")
+	} else if err != nil {
+		check(fail, "cgroup cpu files", "%s", describeError(err))
+	} else {
+		effective = cpu.Effective
+		switch {
+		case len(cpu.Unreadable) > 0 || len(cpu.ReadErrors) > 0:
+			check(warn, "cgroup cpu files", "%d unreadable and %d invalid, the limit may be incomplete", len(cpu.Unreadable), len(cpu.ReadErrors))
+		case cpu.Effective == 0:
+			check(pass, "cgroup cpu files", "readable, no CPU limit")
+		default:
+			check(pass, "cgroup cpu files", "readable, limit %s CPUs at /%s", formatCPUs(cpu.Effective), cpu.LimitedBy)
+		}
+	}
+
+	affinity := 0
+	if n, _, err := getAffinity(d.PID); err != nil {
+		check(fail, "sched_getaffinity(2)", "%v", err)
+	} else if n == 0 {
+		check(fail, "sched_getaffinity(2)", "no CPUs in the affinity mask")
+	} else {
+		affinity = n
+		check(pass, "sched_getaffinity(2)", "%d CPUs", n)
+	}
+	if affinity != 0 && effective > float64(affinity) {
+		check(warn, "quota vs affinity", "quota (%s) exceeds available CPUs (%d)", formatCPUs(effective), affinity)
+	}
+
+	switch n, ok, err := cgroup.EnvGOMAXPROCS(); {
+	case err != nil:
+		check(fail, "$GOMAXPROCS", "%v", err)
+	case !ok:
+		check(pass, "$GOMAXPROCS", "unset")
+	case affinity != 0 && n > affinity:
+		check(warn, "$GOMAXPROCS", "%d exceeds the %d CPUs in the affinity mask", n, affinity)
+	default:
+		check(pass, "$GOMAXPROCS", "%d", n)
+	}
+
+	want := d.GOMAXPROCS()
+	if got := runtime.GOMAXPROCS(-1); got != want {
+		check(warn, "runtime.GOMAXPROCS(-1)", "%d, but %d is recommended", got, want)
+	} else {
+		check(pass, "runtime.GOMAXPROCS(-1)", "%d, as recommended", got)
+	}
+	return checks
+}
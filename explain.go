@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/schmichael/goplay/cgroup"
+)
+
+// printExplain narrates, one step per line, how the recommended GOMAXPROCS
+// in r was reached by d.
+func printExplain(w io.Writer, r report, d *cgroup.Detector) {
+	for _, step := range explain(r, d) {
+		fmt.Fprintln(w, step)
+	}
+}
+
+// explain returns the steps printExplain prints.
+func explain(r report, d *cgroup.Detector) []string {
+	var steps []string
+	step := func(format string, args ...any) {
+		steps = append(steps, fmt.Sprintf(format, args...))
+	}
+	numCPU := fmt.Sprintf("runtime.NumCPU() = %d", r.NumCPU)
+
+	if r.Error != nil {
+		step("Reading the cgroup limits failed (%s), so there is no recommendation.", *r.Error)
+		return steps
+	}
+
+	switch {
+	case r.CgroupVersion == "none":
+		step("No cgroup hierarchy is mounted.")
+	case r.CgroupVersion == "hybrid" && len(r.CgroupLevels) > 0:
+		step("Detected a hybrid cgroup v1 and v2 layout, with CPU limits under /%s.", r.CgroupLevels[len(r.CgroupLevels)-1].Dir)
+	case len(r.CgroupLevels) > 0:
+		step("Detected cgroup %s at /%s.", r.CgroupVersion, r.CgroupLevels[len(r.CgroupLevels)-1].Dir)
+	default:
+		step("Detected cgroup %s.", r.CgroupVersion)
+	}
+	if r.GVisor {
+		step("The process runs under gVisor, whose cgroups are emulated, so they may not reflect what the sandbox enforces.")
+	}
+	if !r.InCgroup && r.CgroupEffective == nil {
+		step("The process is not in a cgroup, so nothing limits its CPU and GOMAXPROCS defaults to %s.", numCPU)
+		return explainEnv(steps, r)
+	}
+	if r.CgroupPath != nil {
+		step("Your cgroup is %s.", *r.CgroupPath)
+	}
+
+	walk := "Walking up to the root, the tightest"
+	switch r.WalkMode {
+	case "leaf-only":
+		walk = "Reading only your own cgroup, the"
+	case "nearest":
+		walk = "Walking up, the nearest"
+	}
+	switch {
+	case r.CgroupEffective == nil:
+		step("No level sets a CPU quota, so GOMAXPROCS defaults to %s.", numCPU)
+		return explainEnv(steps, r)
+	case *r.EffectiveSource == "env":
+		step("The cgroup sets no CPU limit or it could not be read, so -cpu-limit-env takes it from %s = %s CPUs.", *r.LimitedBy, formatCPUs(*r.CgroupEffective))
+	case r.CgroupQuota != nil:
+		step("%s quota was %d/%d = %s CPUs at %s.", walk, *r.CgroupQuota, *r.CgroupPeriod, formatCPUs(*r.CgroupEffective), *r.LimitedBy)
+	default:
+		step("The cpuset at %s allows %s CPUs, fewer than any quota.", *r.LimitedBy, formatCPUs(*r.CgroupEffective))
+	}
+
+	if r.AdjustedSource != nil && *r.AdjustedSource == "proposal" {
+		step("With -proposal-strict the Go 1.25 runtime algorithm applies: the ceiling of the quota, at least 2 but at most %s, giving %d.", numCPU, *r.CgroupAdjusted)
+	} else {
+		limit := *r.CgroupEffective
+		// Detection fails on an invalid clamp, so r.Error would be set.
+		adjusted, _ := d.Adjust(limit)
+		clamp := fmt.Sprintf("the minimum clamp is %d", d.ResolvedMinGOMAXPROCS())
+		if d.MaxGOMAXPROCS != 0 {
+			clamp = fmt.Sprintf("the clamp is %d to %d", d.ResolvedMinGOMAXPROCS(), d.MaxGOMAXPROCS)
+		}
+		step("Rounding %s with %s gives %d, and %s, so the adjusted GOMAXPROCS is %d.",
+			formatCPUs(limit), d.Rounding, int(d.Rounding.Apply(limit)), clamp, adjusted)
+	}
+	return explainEnv(steps, r)
+}
+
+// explainEnv appends to steps the effect of $GOMAXPROCS, if set, and the
+// final recommendation.
+func explainEnv(steps []string, r report) []string {
+	if r.AdjustedSource != nil && *r.AdjustedSource == "env" {
+		steps = append(steps, fmt.Sprintf("However $GOMAXPROCS=%s is set and takes precedence.", *r.GOMAXPROCSEnv))
+	} else if r.GOMAXPROCSEnv != nil {
+		steps = append(steps, fmt.Sprintf("$GOMAXPROCS=%q is set but is not a positive integer, so the runtime ignores it.", *r.GOMAXPROCSEnv))
+	}
+	return append(steps, fmt.Sprintf("So the recommended GOMAXPROCS is %d.", recommended(r)))
+}
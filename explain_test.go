@@ -0,0 +1,31 @@
+package main
+
+import (
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/schmichael/goplay/cgroup"
+)
+
+func TestExplainClamp(t *testing.T) {
+	cases := []struct {
+		name string
+		d    cgroup.Detector
+		want string
+	}{
+		{"library default", cgroup.Detector{}, "Rounding 2.5 with ceil gives 3, and the minimum clamp is 2, so the adjusted GOMAXPROCS is 3."},
+		{"floor", cgroup.Detector{Rounding: cgroup.RoundFloor, MinGOMAXPROCS: 1}, "Rounding 2.5 with floor gives 2, and the minimum clamp is 1, so the adjusted GOMAXPROCS is 2."},
+		{"max", cgroup.Detector{MaxGOMAXPROCS: 2}, "Rounding 2.5 with ceil gives 3, and the clamp is 2 to 2, so the adjusted GOMAXPROCS is 2."},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := tc.d
+			d.FS = os.DirFS(writeTree(t, v2Tree))
+			steps := explain(collect(&d), &d)
+			if !slices.Contains(steps, tc.want) {
+				t.Errorf("explain steps are missing %q:\n%q", tc.want, steps)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// exportFormats maps each -export-format to how it sets an environment
+// variable to a quoted value.
+var exportFormats = map[string]string{
+	"sh":   "export %s=%s\n",
+	"fish": "set -gx %s %s;\n",
+}
+
+// printExport prints shell commands exporting the recommended GOMAXPROCS,
+// and GOMEMLIMIT if one was suggested, for use with eval "$(goplay -export)".
+// Errors and warnings go to stderr so that nothing is exported on error.
+func printExport(w io.Writer, r report, format string) {
+	for _, w := range r.Warnings {
+		fmt.Fprintln(os.Stderr, "WARNING:", w)
+	}
+	if r.Error != nil {
+		fmt.Fprintln(os.Stderr, *r.Error)
+		return
+	}
+
+	line := exportFormats[format]
+	fmt.Fprintf(w, line, "GOMAXPROCS", shellQuote(strconv.Itoa(recommended(r))))
+	if r.SuggestedGOMEMLIMIT != nil {
+		fmt.Fprintf(w, line, "GOMEMLIMIT", shellQuote(strconv.FormatInt(*r.SuggestedGOMEMLIMIT, 10)))
+	}
+}
+
+// printEnv prints the recommended GOMAXPROCS, and GOMEMLIMIT if one was
+// suggested, as KEY=VALUE lines for a systemd EnvironmentFile. Errors and
+// warnings go to stderr.
+func printEnv(w io.Writer, r report) {
+	for _, w := range r.Warnings {
+		fmt.Fprintln(os.Stderr, "WARNING:", w)
+	}
+	if r.Error != nil {
+		fmt.Fprintln(os.Stderr, *r.Error)
+		return
+	}
+
+	fmt.Fprintf(w, "GOMAXPROCS=%d\n", recommended(r))
+	if r.SuggestedGOMEMLIMIT != nil {
+		fmt.Fprintf(w, "GOMEMLIMIT=%d\n", *r.SuggestedGOMEMLIMIT)
+	}
+}
+
+// writeFileAtomic writes data to name via a temporary file in the same
+// directory and a rename, so that readers never see a partial file.
+func writeFileAtomic(name string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(0o644); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), name)
+}
+
+// shellQuote single-quotes s, closing and reopening the quotes around any
+// single quote in s, which works in both sh and fish.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
@@ -0,0 +1,16 @@
+package main
+
+// unlimitedFallback records runtime.NumCPU() in r as the adjusted GOMAXPROCS
+// when there is no CPU limit to derive one from, either because the cgroup
+// is unlimited or because the process is not in a cgroup, so that scripts
+// always get a number. A valid $GOMAXPROCS and detection errors are left
+// alone.
+func unlimitedFallback(r *report) {
+	if r.Error != nil || r.CgroupAdjusted != nil {
+		return
+	}
+	procs := r.NumCPU
+	src := "numcpu"
+	r.CgroupAdjusted = &procs
+	r.AdjustedSource = &src
+}
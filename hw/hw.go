@@ -0,0 +1,37 @@
+// Package hw reads information about the host's hardware that does not come
+// from cgroups, such as its CPU topology. None of it affects the CPU limit
+// detected by package cgroup.
+package hw
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/schmichael/goplay/cgroup"
+)
+
+// hostFS is the host's root filesystem.
+var hostFS = os.DirFS("/")
+
+// rootFS returns fsys, or the host's root filesystem if fsys is nil.
+func rootFS(fsys fs.FS) fs.FS {
+	if fsys == nil {
+		return hostFS
+	}
+	return fsys
+}
+
+// readValue reads a file containing a single value.
+func readValue(fsys fs.FS, filePath string) (string, error) {
+	b, err := fs.ReadFile(fsys, filePath)
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(string(b))
+	if len(fields) != 1 {
+		return "", &cgroup.ParseError{File: filePath, Content: string(b), Err: errors.New("expected a single value")}
+	}
+	return fields[0], nil
+}
@@ -0,0 +1,98 @@
+package hw
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/schmichael/goplay/cgroup"
+)
+
+// resctrlPath is where the resctrl filesystem is mounted.
+const resctrlPath = "sys/fs/resctrl"
+
+// Resctrl is the resctrl group of a process, which allocates cache (Intel
+// CAT, AMD L3 QoS) and memory bandwidth to its tasks. It does not limit CPU
+// time and is not used to derive GOMAXPROCS.
+//
+// Experimental: Resctrl and ReadResctrl may change or be removed in any
+// release.
+type Resctrl struct {
+	// Group is the group's path under the resctrl mount, "/" for the
+	// default group.
+	Group string
+	// Schemata is the group's allocations, one resource per line, e.g.
+	// "L3:0=7ff;1=7ff" where 7ff is the capacity bitmask (CBM) of the cache
+	// ways available on each cache domain.
+	Schemata []string
+}
+
+// ReadResctrl returns the resctrl group of process pid, or of the current
+// process if pid is 0, reading from fsys or the host's root filesystem if
+// fsys is nil. ok is false if resctrl is not mounted or the kernel does not
+// report the process's group in /proc/<pid>/cpu_resctrl_groups. It returns
+// cgroup.ErrNoProcess if pid does not exist.
+//
+// Experimental: ReadResctrl may change or be removed in any release.
+func ReadResctrl(fsys fs.FS, pid int) (r Resctrl, ok bool, err error) {
+	fsys = rootFS(fsys)
+
+	proc := "proc/self"
+	if pid != 0 {
+		proc = path.Join("proc", strconv.Itoa(pid))
+	}
+	groupsFile := path.Join(proc, "cpu_resctrl_groups")
+	content, err := fs.ReadFile(fsys, groupsFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		if _, err := fs.Stat(fsys, proc); pid != 0 && errors.Is(err, fs.ErrNotExist) {
+			return Resctrl{}, false, fmt.Errorf("%w: pid %d", cgroup.ErrNoProcess, pid)
+		}
+		return Resctrl{}, false, nil
+	}
+	if err != nil {
+		return Resctrl{}, false, err
+	}
+
+	// The file lists the control group as "res:/group" and the monitoring
+	// group as "mon:/group", with empty groups if resctrl is not mounted.
+	group, found := "", false
+	for _, line := range strings.Split(string(content), "\n") {
+		if group, found = strings.CutPrefix(line, "res:"); found {
+			break
+		}
+	}
+	if !found {
+		return Resctrl{}, false, &cgroup.ParseError{File: groupsFile, Content: string(content), Err: errors.New("no res: line")}
+	}
+	if group == "" {
+		return Resctrl{}, false, nil
+	}
+	group = path.Join("/", group)
+
+	schemataFile := path.Join(resctrlPath, group, "schemata")
+	file, err := fsys.Open(schemataFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		// resctrl is mounted somewhere else, or the group was removed.
+		return Resctrl{}, false, nil
+	}
+	if err != nil {
+		return Resctrl{}, false, err
+	}
+	defer file.Close()
+
+	r = Resctrl{Group: group}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			r.Schemata = append(r.Schemata, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Resctrl{}, false, fmt.Errorf("reading /%s: %w", schemataFile, err)
+	}
+	return r, true, nil
+}
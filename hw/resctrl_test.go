@@ -0,0 +1,50 @@
+package hw
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	"github.com/schmichael/goplay/cgroup"
+)
+
+func TestReadResctrl(t *testing.T) {
+	// Process 3 does not exist.
+	fsys := fstest.MapFS{
+		"proc/self/cpu_resctrl_groups":  {Data: []byte("res:/batch\nmon:/batch/job\n")},
+		"proc/1/cpu_resctrl_groups":     {Data: []byte("res:\nmon:\n")},
+		"proc/2/cgroup":                 {Data: []byte("0::/\n")},
+		"proc/4/cpu_resctrl_groups":     {Data: []byte("mon:/\n")},
+		"proc/5/cpu_resctrl_groups":     {Data: []byte("res:/removed\n")},
+		"sys/fs/resctrl/batch/schemata": {Data: []byte("    L3:0=7ff;1=7ff\n    MB:0=100;1=100\n")},
+	}
+	cases := []struct {
+		name   string
+		pid    int
+		want   Resctrl
+		wantOK bool
+		err    bool
+	}{
+		{"self", 0, Resctrl{Group: "/batch", Schemata: []string{"L3:0=7ff;1=7ff", "MB:0=100;1=100"}}, true, false},
+		{"not mounted", 1, Resctrl{}, false, false},
+		{"unsupported kernel", 2, Resctrl{}, false, false},
+		{"no res line", 4, Resctrl{}, false, true},
+		{"removed group", 5, Resctrl{}, false, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok, err := ReadResctrl(fsys, tc.pid)
+			if (err != nil) != tc.err {
+				t.Fatalf("ReadResctrl(%d) error = %v, want error %t", tc.pid, err, tc.err)
+			}
+			if ok != tc.wantOK || got.Group != tc.want.Group || !slices.Equal(got.Schemata, tc.want.Schemata) {
+				t.Errorf("ReadResctrl(%d) = %+v, %t, want %+v, %t", tc.pid, got, ok, tc.want, tc.wantOK)
+			}
+		})
+	}
+
+	if _, _, err := ReadResctrl(fsys, 3); !errors.Is(err, cgroup.ErrNoProcess) {
+		t.Errorf("ReadResctrl(3) error = %v, want ErrNoProcess", err)
+	}
+}
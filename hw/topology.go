@@ -0,0 +1,83 @@
+package hw
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// cpuSysPath is where the kernel lists each CPU's topology.
+const cpuSysPath = "sys/devices/system/cpu"
+
+// Topology is the host's CPU topology, from
+// /sys/devices/system/cpu/cpu*/topology. It describes the host rather than
+// the process's cgroup, so it never affects the effective CPU limit.
+type Topology struct {
+	// LogicalCPUs is the number of online CPUs, i.e. hardware threads.
+	LogicalCPUs int
+	// PhysicalCores is the number of distinct cores the online CPUs belong
+	// to.
+	PhysicalCores int
+	// Sockets is the number of distinct physical packages.
+	Sockets int
+}
+
+// SMT returns the number of hardware threads per physical core, e.g. 2 with
+// hyperthreading enabled and 1 without, or 0 if there are no cores.
+func (t Topology) SMT() float64 {
+	if t.PhysicalCores == 0 {
+		return 0
+	}
+	return float64(t.LogicalCPUs) / float64(t.PhysicalCores)
+}
+
+// ReadTopology returns the CPU topology of the host, reading from fsys or
+// the host's root filesystem if fsys is nil. Offline CPUs, which have no
+// topology, are not counted.
+func ReadTopology(fsys fs.FS) (Topology, error) {
+	fsys = rootFS(fsys)
+
+	entries, err := fs.ReadDir(fsys, cpuSysPath)
+	if err != nil {
+		return Topology{}, err
+	}
+
+	cores := map[string]bool{}
+	sockets := map[string]bool{}
+	var t Topology
+	for _, e := range entries {
+		n, ok := strings.CutPrefix(e.Name(), "cpu")
+		if _, err := strconv.Atoi(n); !ok || err != nil {
+			// e.g. cpufreq and cpuidle
+			continue
+		}
+		dir := path.Join(cpuSysPath, e.Name(), "topology")
+
+		// Siblings share a core, so the list of them identifies it. Kernels
+		// before 5.7 only have thread_siblings_list.
+		siblings, err := readValue(fsys, path.Join(dir, "core_cpus_list"))
+		if errors.Is(err, fs.ErrNotExist) {
+			siblings, err = readValue(fsys, path.Join(dir, "thread_siblings_list"))
+		}
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return Topology{}, fmt.Errorf("reading the topology of %s: %w", e.Name(), err)
+		}
+		pkg, err := readValue(fsys, path.Join(dir, "physical_package_id"))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return Topology{}, fmt.Errorf("reading the topology of %s: %w", e.Name(), err)
+		}
+
+		t.LogicalCPUs++
+		cores[siblings] = true
+		sockets[pkg] = true
+	}
+	t.PhysicalCores = len(cores)
+	t.Sockets = len(sockets)
+	return t, nil
+}
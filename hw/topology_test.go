@@ -0,0 +1,41 @@
+package hw
+
+import (
+	"strconv"
+	"testing"
+	"testing/fstest"
+)
+
+func TestReadTopology(t *testing.T) {
+	// Two sockets of two cores with two threads each, with cpu7 offline and
+	// cpu6 on a kernel before 5.7.
+	fsys := fstest.MapFS{
+		"sys/devices/system/cpu/cpufreq/policy0/scaling_governor": {Data: []byte("performance\n")},
+		"sys/devices/system/cpu/cpu7/online":                      {Data: []byte("0\n")},
+	}
+	for cpu, core := range []string{"0-1", "0-1", "2-3", "2-3", "4-5", "4-5", "6-7"} {
+		dir := "sys/devices/system/cpu/cpu" + strconv.Itoa(cpu) + "/topology/"
+		pkg := "0\n"
+		if cpu >= 4 {
+			pkg = "1\n"
+		}
+		name := "core_cpus_list"
+		if cpu == 6 {
+			name = "thread_siblings_list"
+		}
+		fsys[dir+name] = &fstest.MapFile{Data: []byte(core + "\n")}
+		fsys[dir+"physical_package_id"] = &fstest.MapFile{Data: []byte(pkg)}
+	}
+
+	got, err := ReadTopology(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Topology{LogicalCPUs: 7, PhysicalCores: 4, Sockets: 2}
+	if got != want {
+		t.Errorf("ReadTopology() = %+v, want %+v", got, want)
+	}
+	if smt := got.SMT(); smt != 1.75 {
+		t.Errorf("SMT() = %g, want 1.75", smt)
+	}
+}
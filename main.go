@@ -1,251 +1,973 @@
 package main
 
 import (
-	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
 	"math"
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
-	"golang.org/x/sys/unix"
+	"github.com/schmichael/goplay/cgroup"
 )
 
+// schemaVersion is the version of the -json output. Bump it when a field is
+// renamed, removed, or changes meaning; adding a field is compatible.
+const schemaVersion = 1
+
+// report is everything goplay prints. Pointer fields are nil when the value
+// is unset or inapplicable so that they encode as JSON null.
+type report struct {
+	SchemaVersion      int            `json:"schemaVersion"`
+	Root               *string        `json:"root"`
+	NumCPU             int            `json:"numCPU"`
+	GOMAXPROCSEnv      *string        `json:"gomaxprocsEnv"`
+	SchedAffinity      string         `json:"-"`
+	SchedAffinityCount *int           `json:"schedAffinityCount"`
+	NumCPUAffinity     *bool          `json:"numCPUMatchesAffinity"`
+	GoVersion          string         `json:"goVersion"`
+	ContainerAware     bool           `json:"containerAware"`
+	ContainerMaxProcs  *string        `json:"godebugContainermaxprocs"`
+	ContainerMaxSource *string        `json:"godebugContainermaxprocsSource"`
+	RuntimeAware       *int           `json:"runtimeContainerAwareGOMAXPROCS"`
+	RuntimeUnaware     *int           `json:"runtimeNotContainerAwareGOMAXPROCS"`
+	RuntimeGOMAXPROCS  int            `json:"runtimeGOMAXPROCS"`
+	CgroupVersion      string         `json:"cgroupVersion"`
+	InCgroup           bool           `json:"inCgroup"`
+	GVisor             bool           `json:"gvisor"`
+	WalkMode           string         `json:"walkMode"`
+	CgroupPath         *string        `json:"cgroupPath"`
+	DetectedRuntime    *string        `json:"detectedRuntime"`
+	CgroupEffective    *float64       `json:"cgroupEffective"`
+	EffectiveSource    *string        `json:"effectiveSource"`
+	CgroupBurst        *float64       `json:"cgroupBurst"`
+	CgroupBurstRatio   *float64       `json:"cgroupBurstRatio"`
+	LimitedBy          *string        `json:"limitedBy"`
+	LimitedByUnit      *string        `json:"limitedByUnit"`
+	CgroupQuota        *int64         `json:"cgroupQuota"`
+	CgroupPeriod       *int64         `json:"cgroupPeriod"`
+	CgroupLeaf         *float64       `json:"cgroupLeaf"`
+	CgroupWeight       *float64       `json:"cgroupWeight"`
+	CgroupLevels       []cgroup.Level `json:"-"`
+	CgroupHierarchy    float64        `json:"-"`
+	CgroupAdjusted     *int           `json:"cgroupAdjusted"`
+	AdjustedSource     *string        `json:"adjustedSource"`
+	DetectedAdjusted   *int           `json:"-"`
+	CgroupMemoryLimit  *int64         `json:"cgroupMemoryLimit"`
+	CgroupMemoryHigh   *int64         `json:"cgroupMemoryHigh"`
+	CgroupPidsLimit    *int64         `json:"cgroupPidsLimit"`
+	CgroupPeriods      *int64         `json:"cgroupPeriods"`
+	CgroupThrottled    *int64         `json:"cgroupThrottledPeriods"`
+	CgroupThrottledSec *float64       `json:"cgroupThrottledSeconds"`
+	CgroupCPUUsageSec  *float64       `json:"cgroupCPUUsageSeconds"`
+	Error              *string        `json:"error"`
+	Warnings           []string       `json:"warnings"`
+
+	// Only set with -set.
+	SetPrevious *int    `json:"setPrevious"`
+	SetNew      *int    `json:"setNew"`
+	SetError    *string `json:"setError"`
+
+	// Only set with -json and -interval or -watch.
+	Time *string `json:"time"`
+
+	// Only set with -memheadroom.
+	SuggestedGOMEMLIMIT *int64 `json:"suggestedGOMEMLIMIT"`
+
+	// Only set with -check.
+	CheckRuntime     *int  `json:"checkRuntime"`
+	CheckRecommended *int  `json:"checkRecommended"`
+	CheckMatch       *bool `json:"checkMatch"`
+
+	// Only set with -assert-cpus.
+	AssertCPUs      *float64 `json:"assertCPUs"`
+	AssertTolerance *float64 `json:"assertTolerance"`
+	AssertMatch     *bool    `json:"assertMatch"`
+
+	// Only set with -compare.
+	Automaxprocs       *int    `json:"automaxprocs"`
+	AutomaxprocsReason *string `json:"automaxprocsReason"`
+
+	// Only set with -timing.
+	DurationMs  *float64     `json:"durationMs"`
+	FileTimings []fileTiming `json:"-"`
+
+	// Only set with -resctrl. Experimental.
+	ResctrlGroup    *string  `json:"resctrlGroup"`
+	ResctrlSchemata []string `json:"resctrlSchemata"`
+
+	// Only set with -topology.
+	TopologyLogicalCPUs   *int     `json:"topologyLogicalCPUs"`
+	TopologyPhysicalCores *int     `json:"topologyPhysicalCores"`
+	TopologySockets       *int     `json:"topologySockets"`
+	TopologySMT           *float64 `json:"topologySMT"`
+}
+
 func main() {
-	fmt.Println("Go Container-aware GOMAXPROCS Debug Info")
-	fmt.Println("Based on https://github.com/golang/go/issues/73193#user-content-proposal")
-	fmt.Println("")
-	fmt.Println("NumCPU:                 ", runtime.NumCPU())
-	fmt.Println("$GOMAXPROCS:            ", os.Getenv("GOMAXPROCS"))
-	fmt.Println("sched_getaffinity(2):   ", getaffin())
-	fmt.Println("runtime.GOMAXPROCS(-1): ", runtime.GOMAXPROCS(-1))
-	fmt.Print("cgroup limit:            ")
-
-	eff, adj, err := cgroupLimit()
-	if err != nil {
-		fmt.Println("error retrieving cgroup limits:", err.Error())
-	} else if eff == 0 && adj == 0 {
-		fmt.Println("not in cgroup")
-	} else {
-		fmt.Printf("effective: %f -- adjusted: %f\n", eff, adj)
-	}
+	os.Exit(run(os.Stdout, os.Args))
 }
 
-func getaffin() string {
-	cpuset := &unix.CPUSet{}
-	err := unix.SchedGetaffinity(0, cpuset)
+// run runs goplay with the command line args, writing output to w and errors
+// to stderr, and returns the exit code.
+func run(w io.Writer, args []string) int {
+	// "goplay doctor" takes the same flags, e.g. -root and -pid.
+	doctorMode := len(args) > 1 && args[1] == "doctor"
+	if doctorMode {
+		args = append(args[:1:1], args[2:]...)
+	}
+
+	flags := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	jsonOut := flags.Bool("json", false, "print a single JSON object instead of text (same as -format json)")
+	quiet := flags.Bool("quiet", false, "print only the recommended GOMAXPROCS, with errors on stderr")
+	millicpu := flags.Bool("millicpu", false, "print only the effective CPU limit in Kubernetes millicores, with errors on stderr")
+	export := flags.Bool("export", false, "print shell commands exporting the recommended GOMAXPROCS (and GOMEMLIMIT with -memheadroom) for eval")
+	exportFormat := flags.String("export-format", "sh", "shell `syntax` for -export: sh or fish")
+	format := flags.String("format", "text", "output `format`: text, json, tsv, or env")
+	out := flags.String("out", "", "atomically write the output to `file` instead of stdout")
+	set := flags.Bool("set", false, "set runtime.GOMAXPROCS to the adjusted value")
+	force := flags.Bool("force", false, "with -set, override $GOMAXPROCS if it is set")
+	watchMode := flags.Bool("watch", false, "reprint whenever the cgroup CPU limit changes, until interrupted")
+	explainMode := flags.Bool("explain", false, "narrate each step of how the recommended GOMAXPROCS is reached")
+	verbose := flags.Bool("verbose", false, "print additional detail such as the raw affinity mask and the limit at each cgroup level")
+	metricsAddr := flags.String("metrics", "", "serve Prometheus metrics on `addr` (e.g. :9090) instead of printing")
+	minProcs := flags.Int("min", 2, "minimum adjusted GOMAXPROCS")
+	maxProcs := flags.Int("max", 0, "maximum adjusted GOMAXPROCS, applied after -min (0 for no maximum)")
+	round := flags.String("round", "ceil", "how to round a fractional CPU limit: ceil, floor, or round")
+	compareMode := flags.Bool("compare", false, "compare against what go.uber.org/automaxprocs would choose")
+	checkMode := flags.Bool("check", false, "exit 1 if runtime.GOMAXPROCS(-1) differs from the recommended value")
+	assertCPUsFlag := flags.Float64("assert-cpus", 0, "exit 1 unless the effective CPU limit is `cpus` (0 for no limit), e.g. as a CI gate")
+	tolerance := flags.Float64("tolerance", 0, "with -assert-cpus, the largest allowed difference in CPUs (e.g. 0.01)")
+	root := flags.String("root", "", "read cgroup and proc files from a snapshot under `dir` instead of the live host")
+	requireCgroup := flags.Bool("require-cgroup", false, "exit 2 if the process is not in a cgroup")
+	logMode := flags.Bool("log", false, "log detection results as structured log/slog text instead of printing")
+	interval := flags.Duration("interval", 0, "re-run detection every `interval` (e.g. 5s), printing one line each time")
+	count := flags.Int("count", 0, "with -interval, stop after `n` iterations (0 runs until interrupted)")
+	memHeadroom := flags.Float64("memheadroom", 0, "print a suggested GOMEMLIMIT leaving `fraction` of the memory limit as headroom (e.g. 0.1)")
+	serveAddr := flags.String("serve", "", "serve JSON on GET /cpu and apply GOMAXPROCS on POST /apply at `addr` (e.g. :8080)")
+	reread := flags.Bool("reread", false, "read each CPU quota twice and fail if a live resize changes it in between")
+	leafOnly := flags.Bool("leaf-only", false, "read limits only from the process's own cgroup, ignoring ancestors")
+	nearest := flags.Bool("nearest", false, "use the first limit found walking up from the process's cgroup instead of the minimum")
+	strict := flags.Bool("proposal-strict", false, "compute the adjusted GOMAXPROCS exactly as the Go 1.25 runtime does, ignoring cpusets, -min, and -round")
+	resctrlMode := flags.Bool("resctrl", false, "also report the resctrl cache allocation group (experimental)")
+	topologyMode := flags.Bool("topology", false, "also report physical cores, logical CPUs, and the SMT factor from /sys/devices/system/cpu")
+	timing := flags.Bool("timing", false, "report how long detection took, and with -verbose how long each file read took")
+	fallback := flags.String("unlimited-fallback", "none", "adjusted GOMAXPROCS when the cgroup is unlimited or absent: none, or numcpu for runtime.NumCPU()")
+	cpuLimitEnv := flags.String("cpu-limit-env", "", "fall back to the CPU limit in environment variable `name` (e.g. MESOS_CPU_LIMIT) when the cgroup limit is unreadable or unlimited")
+	controller := flags.String("controller", "", "print only the limit of one `controller`: cpu, memory, or pids")
+	pid := flags.Int("pid", 0, "report the limits of process `pid` instead of goplay itself")
+	if err := flags.Parse(args[1:]); errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	} else if err != nil {
+		return exitUsage
+	}
+
+	if *minProcs < 1 {
+		fmt.Fprintln(os.Stderr, "-min must be a positive integer")
+		return exitUsage
+	}
+	if *maxProcs != 0 && *maxProcs < *minProcs {
+		fmt.Fprintln(os.Stderr, "-max must be 0 or at least -min")
+		return exitUsage
+	}
+	rounding, err := cgroup.ParseRounding(*round)
 	if err != nil {
-		return "error: " + err.Error()
+		fmt.Fprintln(os.Stderr, "-round:", err)
+		return exitUsage
+	}
+	if *interval < 0 || *count < 0 || (*count > 0 && *interval == 0) {
+		fmt.Fprintln(os.Stderr, "-count requires a positive -interval")
+		return exitUsage
+	}
+	if *memHeadroom < 0 || *memHeadroom >= 1 {
+		fmt.Fprintln(os.Stderr, "-memheadroom must be at least 0 and less than 1")
+		return exitUsage
+	}
+	if *assertCPUsFlag < 0 || *tolerance < 0 {
+		fmt.Fprintln(os.Stderr, "-assert-cpus and -tolerance cannot be negative")
+		return exitUsage
+	}
+	if *pid < 0 {
+		fmt.Fprintln(os.Stderr, "-pid must be a positive integer")
+		return exitUsage
+	}
+	if *strict {
+		conflict := false
+		flags.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "min", "max", "round", "leaf-only", "nearest", "set", "watch", "interval", "serve", "metrics":
+				fmt.Fprintf(os.Stderr, "-proposal-strict cannot be used with -%s\n", f.Name)
+				conflict = true
+			}
+		})
+		if conflict {
+			return exitUsage
+		}
+	}
+	if *interval > 0 {
+		// poll prints only its one-line summary or NDJSON, so reject the
+		// flags it would otherwise silently ignore.
+		conflict := false
+		flags.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "quiet", "millicpu", "export", "explain", "set", "watch":
+				fmt.Fprintf(os.Stderr, "-interval cannot be used with -%s\n", f.Name)
+				conflict = true
+			}
+		})
+		if *format != "text" && *format != "json" && !*jsonOut {
+			fmt.Fprintf(os.Stderr, "-interval cannot be used with -format %s\n", *format)
+			conflict = true
+		}
+		if conflict {
+			return exitUsage
+		}
+	}
+	if *fallback != "none" && *fallback != "numcpu" {
+		fmt.Fprintf(os.Stderr, "-unlimited-fallback: unknown value %q\n", *fallback)
+		return exitUsage
+	}
+	if *controller != "" && !slices.Contains(controllers, *controller) {
+		fmt.Fprintf(os.Stderr, "-controller: unknown controller %q\n", *controller)
+		return exitUsage
+	}
+	if *leafOnly && *nearest {
+		fmt.Fprintln(os.Stderr, "-leaf-only and -nearest cannot be used together")
+		return exitUsage
+	}
+	d := &cgroup.Detector{MinGOMAXPROCS: *minProcs, MaxGOMAXPROCS: *maxProcs, Rounding: rounding, PID: *pid, LeafOnly: *leafOnly, Nearest: *nearest, Reread: *reread, CPULimitEnv: *cpuLimitEnv}
+	if *root != "" {
+		if *set || *watchMode || *serveAddr != "" {
+			fmt.Fprintln(os.Stderr, "-set, -watch, and -serve cannot be used with -root")
+			return exitUsage
+		}
+		snapshot, closer, err := openSnapshot(*root)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "-root:", err)
+			return exitUsage
+		}
+		defer closer.Close()
+		d.FS = snapshot
+	}
+	if d.CgroupV1Path, err = envPath("GOPLAY_CGROUP_V1_PATH"); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+	if d.CgroupV2Path, err = envPath("GOPLAY_CGROUP_V2_PATH"); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
 	}
-	return fmt.Sprintf("%v", *cpuset)
-}
 
-// gemini code
+	if doctorMode {
+		return doctor(w, d)
+	}
 
-const (
-	// cgroupV1 CPU controller path
-	cgroupV1CPUPath = "/sys/fs/cgroup/cpu"
-	// cgroupV2 root path
-	cgroupV2Path = "/sys/fs/cgroup"
-	// Unlimited quota value for cgroup v1
-	cgroupV1UnlimitedQuota = -1
-)
+	if *controller != "" {
+		return printController(w, d, *controller)
+	}
 
-// main is the entry point of the program.
-func cgroupLimit() (float64, float64, error) {
-	effectiveLimit, err := getEffectiveCPULimit()
-	if err != nil {
-		return 0, 0, err
+	if *serveAddr != "" {
+		if err := serve(*serveAddr, d, *set, *force); err != nil {
+			fmt.Fprintln(os.Stderr, "error serving:", err)
+			return exitError
+		}
+		return exitOK
 	}
-	if effectiveLimit == 0 {
-		return 0, 0, nil
+
+	if *metricsAddr != "" {
+		if err := serveMetrics(*metricsAddr, d); err != nil {
+			fmt.Fprintln(os.Stderr, "error serving metrics:", err)
+			return exitError
+		}
+		return exitOK
 	}
 
-	// The adjusted CPU limit is the maximum of 2 and the ceiling of the effective limit.
-	// This ensures a minimum value of 2 as per the requirements.
-	adjustedLimit := math.Max(2.0, math.Ceil(effectiveLimit))
+	if *jsonOut {
+		*format = "json"
+	}
+	var printTo func(io.Writer, report)
+	switch *format {
+	case "text":
+		printTo = func(w io.Writer, r report) { printText(w, r, *verbose, useColor(w)) }
+	case "json":
+		printTo = printJSON
+		if *watchMode {
+			printTo = func(w io.Writer, r report) {
+				if err := printJSONLine(w, r); err != nil {
+					fmt.Fprintln(os.Stderr, "error encoding json:", err)
+				}
+			}
+		}
+	case "tsv":
+		printTo = printTSV
+	case "env":
+		printTo = printEnv
+	default:
+		fmt.Fprintf(os.Stderr, "-format: unknown format %q\n", *format)
+		return exitUsage
+	}
+	if *quiet {
+		printTo = printQuiet
+	}
+	if *millicpu {
+		printTo = printMillicpu
+	}
+	if *export {
+		if _, ok := exportFormats[*exportFormat]; !ok {
+			fmt.Fprintf(os.Stderr, "-export-format: unknown format %q\n", *exportFormat)
+			return exitUsage
+		}
+		printTo = func(w io.Writer, r report) { printExport(w, r, *exportFormat) }
+	}
+	if *explainMode {
+		printTo = func(w io.Writer, r report) { printExplain(w, r, d) }
+	}
+	if *logMode {
+		// The Detector logs each detection, so there is nothing more to
+		// print.
+		d.Logger = slog.New(slog.NewTextHandler(w, nil))
+		printTo = func(io.Writer, report) {}
+	}
+	var outBuf bytes.Buffer
+	if *out != "" {
+		if *interval > 0 || *watchMode {
+			fmt.Fprintln(os.Stderr, "-out cannot be used with -interval or -watch")
+			return exitUsage
+		}
+		if fi, err := os.Stat(filepath.Dir(*out)); err != nil {
+			fmt.Fprintln(os.Stderr, "-out:", err)
+			return exitUsage
+		} else if !fi.IsDir() {
+			fmt.Fprintf(os.Stderr, "-out: %s is not a directory\n", filepath.Dir(*out))
+			return exitUsage
+		}
+		w = &outBuf
+	}
+	printReport := func(r report) { printTo(w, r) }
 
-	return effectiveLimit, adjustedLimit, nil
-}
+	if *interval > 0 {
+		if err := poll(w, d, *interval, *count, *format == "json"); err != nil {
+			fmt.Fprintln(os.Stderr, "error polling cgroup limits:", err)
+			return exitError
+		}
+		return exitOK
+	}
 
-// getEffectiveCPULimit determines the effective CPU limit by traversing the cgroup hierarchy.
-// It returns the minimum CPU limit found in the hierarchy.
-func getEffectiveCPULimit() (float64, error) {
-	// Check if we are in a cgroup v2 environment first.
-	// The existence of "cgroup.controllers" is a good indicator of a v2 hierarchy.
-	if _, err := os.Stat(filepath.Join(cgroupV2Path, "cgroup.controllers")); err == nil {
-		return getCgroupV2Limit()
+	if *watchMode {
+		if err := watch(d, *set, *force, printReport); err != nil {
+			fmt.Fprintln(os.Stderr, "error watching cgroup limits:", err)
+			return exitError
+		}
+		return exitOK
 	}
 
-	// If not v2, assume v1.
-	if _, err := os.Stat(cgroupV1CPUPath); err == nil {
-		return getCgroupV1Limit()
+	var tfs *timingFS
+	if *timing && (d.FS != nil || runtime.GOOS == "linux") {
+		// Leave FS nil elsewhere so that platform detection, such as
+		// Windows job objects, still applies.
+		tfs = &timingFS{fsys: d.FS}
+		if tfs.fsys == nil {
+			tfs.fsys = os.DirFS("/")
+		}
+		d.FS = tfs
+	}
+	start := time.Now()
+	r := collect(d)
+	if *timing {
+		ms := float64(time.Since(start).Microseconds()) / 1000
+		r.DurationMs = &ms
+		if tfs != nil {
+			r.FileTimings = tfs.reads
+		}
+	}
+	if *root != "" {
+		r.Root = root
+	}
+	assertMode := false
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "memheadroom":
+			suggestGOMEMLIMIT(d, &r, *memHeadroom)
+		case "assert-cpus":
+			assertMode = true
+		}
+	})
+	if *strict {
+		proposalStrict(&r)
+	}
+	if *fallback == "numcpu" {
+		unlimitedFallback(&r)
+	}
+	if *compareMode {
+		compare(&r)
+	}
+	if *resctrlMode {
+		resctrl(d, &r)
+	}
+	if *topologyMode {
+		topology(d, &r)
+	}
+	if *set {
+		setGOMAXPROCS(&r, *force)
+	}
+	if *checkMode {
+		check(&r)
 	}
+	if assertMode {
+		assertCPUs(&r, *assertCPUsFlag, *tolerance)
+	}
+	printReport(r)
+	if *out != "" {
+		// Leave any previous file in place rather than replace it with
+		// incomplete output.
+		if r.Error != nil {
+			return exitError
+		}
+		if err := writeFileAtomic(*out, outBuf.Bytes()); err != nil {
+			fmt.Fprintln(os.Stderr, "-out:", err)
+			return exitError
+		}
+	}
+	return exitCode(r, *requireCgroup)
+}
 
-	return 0, nil
+// Exit codes. Invalid flags exit with EX_USAGE from sysexits.h, so scripts
+// can tell them apart from not being in a cgroup.
+const (
+	exitOK          = 0
+	exitError       = 1 // detection failed, or -check or -assert-cpus found a mismatch
+	exitNotInCgroup = 2 // with -require-cgroup
+	exitUsage       = 64
+)
+
+// exitCode returns the exit code for r.
+func exitCode(r report, requireCgroup bool) int {
+	switch {
+	case r.Error != nil:
+		return exitError
+	case requireCgroup && !r.InCgroup:
+		return exitNotInCgroup
+	case r.CheckMatch != nil && !*r.CheckMatch:
+		return exitError
+	case r.AssertMatch != nil && !*r.AssertMatch:
+		return exitError
+	default:
+		return exitOK
+	}
 }
 
-// getCgroupV1Limit handles the logic for cgroup v1.
-func getCgroupV1Limit() (float64, error) {
-	cgroupPath, err := getProcessCgroupPath("cpu")
+// envPath returns the host path in the environment variable name relative to
+// "/", as the cgroup package expects. It returns "" if name is unset.
+func envPath(name string) (string, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return "", nil
+	}
+	abs, err := filepath.Abs(v)
 	if err != nil {
-		return 0, fmt.Errorf("failed to get cgroup v1 path: %w", err)
+		return "", fmt.Errorf("$%s: %w", name, err)
 	}
-
-	// The full path to the process's specific cgroup directory.
-	fullPath := filepath.Join(cgroupV1CPUPath, cgroupPath)
-	return walkHierarchy(fullPath, calculateV1CPUQuota, cgroupV1CPUPath)
+	rel := strings.TrimPrefix(filepath.ToSlash(abs), "/")
+	if rel == "" {
+		rel = "."
+	}
+	return rel, nil
 }
 
-// getCgroupV2Limit handles the logic for cgroup v2.
-func getCgroupV2Limit() (float64, error) {
-	cgroupPath, err := getProcessCgroupPath("") // For v2, the controller name is not prefixed in /proc/self/cgroup
+// getAffinity returns the number of CPUs in the affinity mask of process pid
+// (0 for goplay itself) and the raw mask. It is a variable so that the
+// sched_getaffinity(2) syscall can be stubbed out.
+var getAffinity = getaffin
+
+func collect(d *cgroup.Detector) report {
+	r := report{
+		SchemaVersion:  schemaVersion,
+		GoVersion:      runtime.Version(),
+		ContainerAware: containerAware(runtime.Version()),
+		Warnings:       []string{},
+	}
+	switch {
+	case d.LeafOnly:
+		r.WalkMode = "leaf-only"
+	case d.Nearest:
+		r.WalkMode = "nearest"
+	default:
+		r.WalkMode = "hierarchy"
+	}
+	if v, ok := os.LookupEnv("GOMAXPROCS"); ok {
+		r.GOMAXPROCSEnv = &v
+	}
+	if n, mask, err := getAffinity(d.PID); err != nil {
+		r.SchedAffinity = "error: " + err.Error()
+	} else {
+		r.SchedAffinity = mask
+		r.SchedAffinityCount = &n
+		if n == 0 {
+			// The kernel should never allow an empty mask, so the node is
+			// broken and the runtime cannot schedule normally.
+			r.Warnings = append(r.Warnings, "sched_getaffinity(2) reported no CPUs in the affinity mask")
+		}
+	}
+
+	info, err := d.Detect()
+	r.NumCPU = info.NumCPU
+	r.RuntimeGOMAXPROCS = info.RuntimeGOMAXPROCS
+	if r.SchedAffinityCount != nil {
+		// runtime.NumCPU() is the affinity count at startup, so they only
+		// differ if the mask changed since or -pid names another process.
+		match := r.NumCPU == *r.SchedAffinityCount
+		r.NumCPUAffinity = &match
+	}
+	r.CgroupVersion = info.CgroupVersion
+	r.GVisor = info.GVisor
+	r.Warnings = append(r.Warnings, info.Warnings...)
+	if errors.Is(err, cgroup.ErrPlatformUnsupported) {
+		// There are no cgroups to be in, so report it as for a process that
+		// is not in one rather than as a failed detection.
+		r.Warnings = append(r.Warnings, describeError(err))
+		err = nil
+	}
 	if err != nil {
-		return 0, fmt.Errorf("failed to get cgroup v2 path: %w", err)
+		msg := describeError(err)
+		r.Error = &msg
+		return r
+	}
+	r.InCgroup = info.InCgroup
+	cpu := info.CPU
+	if p := info.CgroupPath; p != "" {
+		r.CgroupPath = &p
+		if names := cgroup.ContainerRuntimes(p); len(names) > 0 {
+			rt := strings.Join(names, "/")
+			r.DetectedRuntime = &rt
+		}
+	}
+	if cpu.Leaf != 0 {
+		r.CgroupLeaf = &cpu.Leaf
+	}
+	if cpu.Weight != 0 {
+		r.CgroupWeight = &cpu.Weight
+	}
+	r.CgroupLevels = cpu.Levels
+	r.CgroupHierarchy = cpu.Hierarchy
+	if info.EffectiveCPU != 0 {
+		src, from := "cgroup", "cgroup"
+		r.CgroupEffective = &info.EffectiveCPU
+		r.EffectiveSource = &from
+		r.CgroupBurst = &cpu.Burst
+		if ratio := cpu.BurstRatio(); ratio != 0 {
+			r.CgroupBurstRatio = &ratio
+		}
+		limitedBy := "/" + info.LimitedByPath
+		if cpu.FromEnv != "" {
+			src, from = "cpu-limit-env", "env"
+			limitedBy = "$" + cpu.FromEnv
+		}
+		r.LimitedBy = &limitedBy
+		if unit, ok := cgroup.SystemdUnit(info.LimitedByPath); ok {
+			r.LimitedByUnit = &unit
+		}
+		if cpu.Period != 0 {
+			r.CgroupQuota = &cpu.Quota
+			r.CgroupPeriod = &cpu.Period
+		}
+		r.CgroupAdjusted = &info.AdjustedGOMAXPROCS
+		r.AdjustedSource = &src
+		r.DetectedAdjusted = &info.AdjustedGOMAXPROCS
 	}
 
-	// The full path to the process's specific cgroup directory.
-	fullPath := filepath.Join(cgroupV2Path, cgroupPath)
-	return walkHierarchy(fullPath, calculateV2CPUQuota, cgroupV2Path)
-}
+	// The runtime cannot run on more CPUs than the affinity mask allows,
+	// e.g. when taskset and cgroups are both in play. The mask is the
+	// host's, so it says nothing about a snapshot read with -root.
+	if d.FS == nil && r.SchedAffinityCount != nil && *r.SchedAffinityCount != 0 && info.EffectiveCPU > float64(*r.SchedAffinityCount) {
+		r.Warnings = append(r.Warnings, fmt.Sprintf("quota (%g) exceeds available CPUs (%d)", info.EffectiveCPU, *r.SchedAffinityCount))
+	}
 
-// getProcessCgroupPath parses /proc/self/cgroup to find the path for a specific controller.
-func getProcessCgroupPath(controller string) (string, error) {
-	file, err := os.Open("/proc/self/cgroup")
-	if err != nil {
-		return "", err
+	// The runtime prioritizes $GOMAXPROCS over the cgroup limit. An invalid
+	// value is among the Detect warnings.
+	if n, ok, err := cgroup.EnvGOMAXPROCS(); err == nil && ok {
+		src := "env"
+		r.CgroupAdjusted = &n
+		r.AdjustedSource = &src
 	}
-	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-		parts := strings.Split(line, ":")
-		if len(parts) != 3 {
-			continue
+	if v, source := godebug("containermaxprocs"); v != "" {
+		r.ContainerMaxProcs = &v
+		r.ContainerMaxSource = &source
+		if v == "0" {
+			r.ContainerAware = false
 		}
+	}
+	aware, unaware := runtimeGOMAXPROCS(r)
+	r.RuntimeAware = &aware
+	r.RuntimeUnaware = &unaware
 
-		// For cgroup v1, the format is "id:controllers:path".
-		// We look for the 'cpu' controller.
-		// For cgroup v2, the format is "0::path".
-		if (controller != "" && strings.Contains(parts[1], controller)) || (controller == "" && parts[1] == "") {
-			return parts[2], nil
-		}
+	if info.MemoryLimit != 0 {
+		r.CgroupMemoryLimit = &info.MemoryLimit
+	}
+	if info.MemoryHigh != 0 {
+		r.CgroupMemoryHigh = &info.MemoryHigh
 	}
 
-	if err := scanner.Err(); err != nil {
-		return "", err
+	if info.PidsLimit != 0 {
+		r.CgroupPidsLimit = &info.PidsLimit
 	}
+	if t := info.Throttling; t != nil {
+		sec := t.ThrottledTime.Seconds()
+		r.CgroupPeriods = &t.Periods
+		r.CgroupThrottled = &t.ThrottledPeriods
+		r.CgroupThrottledSec = &sec
+	}
+	// cpuacct.usage only exists in cgroup v1.
+	if info.CPUUsage != nil {
+		sec := info.CPUUsage.Seconds()
+		r.CgroupCPUUsageSec = &sec
+	}
+	return r
+}
 
-	return "", fmt.Errorf("cgroup path for controller '%s' not found in /proc/self/cgroup", controller)
+// describeError explains why cgroup limits could not be retrieved.
+func describeError(err error) string {
+	switch {
+	case errors.Is(err, fs.ErrPermission):
+		return "permission denied reading cgroup files: " + err.Error()
+	case errors.Is(err, cgroup.ErrPlatformUnsupported):
+		return err.Error()
+	case errors.Is(err, cgroup.ErrCgroupUnsupported):
+		return "unsupported cgroup configuration: " + err.Error()
+	default:
+		return "error retrieving cgroup limits: " + err.Error()
+	}
 }
 
-// walkHierarchy traverses up the cgroup directory tree from a starting path
-// up to a root path, calculating the CPU limit at each level.
-// It returns the minimum limit found.
-func walkHierarchy(startPath string, calcFunc func(string) (float64, error), rootPath string) (float64, error) {
-	minLimit := math.Inf(1) // Initialize with positive infinity
-	currentPath := startPath
+// suggestGOMEMLIMIT records in r a GOMEMLIMIT leaving headroom of the memory
+// limit free. Nothing is recorded if there is no memory limit.
+func suggestGOMEMLIMIT(d *cgroup.Detector, r *report, headroom float64) {
+	limit, err := d.SuggestGOMEMLIMIT(headroom)
+	if err != nil || limit == 0 {
+		return
+	}
+	r.SuggestedGOMEMLIMIT = &limit
+}
 
-	for {
-		limit, err := calcFunc(currentPath)
-		if err != nil {
-			// It's possible for some levels not to have limits set, so we don't error out,
-			// but we log it for debugging purposes.
-			// fmt.Fprintf(os.Stderr, "Debug: could not calculate limit for %s: %v\n", currentPath, err)
+// setGOMAXPROCS applies the adjusted GOMAXPROCS detected in r and records
+// the transition in r. It does not re-read the cgroup, so what is applied is
+// what r reports.
+func setGOMAXPROCS(r *report, force bool) {
+	prev := runtime.GOMAXPROCS(-1)
+	r.SetPrevious = &prev
+
+	if _, ok := os.LookupEnv("GOMAXPROCS"); ok && !force {
+		msg := "refusing to override $GOMAXPROCS without -force"
+		r.SetError = &msg
+		return
+	}
+	if r.Error != nil {
+		r.SetError = r.Error
+		return
+	}
+	// Leave GOMAXPROCS alone outside a cgroup or without a CPU limit.
+	procs := prev
+	if r.DetectedAdjusted != nil {
+		procs = *r.DetectedAdjusted
+		runtime.GOMAXPROCS(procs)
+	}
+	r.SetNew = &procs
+}
+
+// printText prints r for a person to read, highlighting warnings and the
+// recommended GOMAXPROCS with c.
+func printText(w io.Writer, r report, verbose bool, c colorer) {
+	env := ""
+	if r.GOMAXPROCSEnv != nil {
+		env = *r.GOMAXPROCSEnv
+	}
+
+	fmt.Fprintln(w, "Go Container-aware GOMAXPROCS Debug Info")
+	fmt.Fprintln(w, "Based on https://github.com/golang/go/issues/73193#user-content-proposal")
+	fmt.Fprintln(w)
+	if r.Root != nil {
+		fmt.Fprintln(w, "OFFLINE: cgroup values are from the snapshot at", *r.Root)
+		fmt.Fprintln(w, "         NumCPU, affinity, and runtime values are from this host")
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintln(w, "NumCPU:                 ", r.NumCPU)
+	fmt.Fprintln(w, "$GOMAXPROCS:            ", env)
+	if r.SchedAffinityCount != nil {
+		fmt.Fprintln(w, "sched_getaffinity count:", *r.SchedAffinityCount)
+		if *r.NumCPUAffinity {
+			fmt.Fprintln(w, "NumCPU matches affinity: yes")
 		} else {
-			// Update the minimum limit if the current one is smaller.
-			minLimit = math.Min(minLimit, limit)
+			fmt.Fprintln(w, "NumCPU matches affinity: no")
+		}
+		if verbose {
+			fmt.Fprintln(w, "sched_getaffinity mask: ", r.SchedAffinity)
 		}
+	} else {
+		fmt.Fprintln(w, "sched_getaffinity(2):   ", r.SchedAffinity)
+	}
+	if r.ContainerAware {
+		fmt.Fprintln(w, "runtime.Version():      ", r.GoVersion, "(container-aware GOMAXPROCS)")
+	} else {
+		fmt.Fprintln(w, "runtime.Version():      ", r.GoVersion, "(not container-aware, GOMAXPROCS defaults to NumCPU)")
+	}
+	fmt.Fprintln(w, "runtime.GOMAXPROCS(-1): ", r.RuntimeGOMAXPROCS)
+	fmt.Fprintln(w, "cgroup version:         ", r.CgroupVersion)
+	switch r.WalkMode {
+	case "leaf-only":
+		fmt.Fprint(w, "cgroup leaf-only limit:  ")
+	case "nearest":
+		fmt.Fprint(w, "cgroup nearest limit:    ")
+	default:
+		fmt.Fprint(w, "cgroup limit:            ")
+	}
 
-		// Stop if we have reached the root of the cgroup filesystem.
-		if currentPath == rootPath || currentPath == "/" {
-			break
+	adjusted := ""
+	if r.CgroupAdjusted != nil {
+		adjusted = " -- adjusted: " + c.green(strconv.Itoa(*r.CgroupAdjusted))
+		switch *r.AdjustedSource {
+		case "env":
+			adjusted += " (from $GOMAXPROCS)"
+		case "proposal":
+			adjusted += " (Go 1.25 runtime algorithm)"
+		case "numcpu":
+			adjusted += " (runtime.NumCPU(), no CPU limit)"
+		}
+	}
+	if r.Error != nil {
+		fmt.Fprintln(w, c.red(*r.Error))
+	} else if r.CgroupEffective == nil && r.GVisor {
+		fmt.Fprintf(w, "unknown (gVisor sandbox)%s\n", adjusted)
+	} else if r.CgroupEffective == nil && r.InCgroup {
+		fmt.Fprintf(w, "unlimited (cgroup present, no CPU limit set)%s\n", adjusted)
+	} else if r.CgroupEffective == nil && r.CgroupVersion == "none" {
+		fmt.Fprintf(w, "not in cgroup (no cgroup hierarchy mounted)%s\n", adjusted)
+	} else if r.CgroupEffective == nil {
+		fmt.Fprintf(w, "not in cgroup%s\n", adjusted)
+	} else {
+		from := ""
+		if *r.EffectiveSource == "env" {
+			from = " (from " + *r.LimitedBy + ")"
+		}
+		fmt.Fprintf(w, "effective: %s%s%s\n", formatCPUs(*r.CgroupEffective), from, adjusted)
+		fmt.Fprintln(w, "limited by:             ", *r.LimitedBy)
+		if r.WalkMode != "leaf-only" && *r.EffectiveSource == "cgroup" {
+			// Show whether the process's own cgroup or a parent binds, since
+			// a pod without a quota of its own can still be limited.
+			switch {
+			case r.CgroupLeaf == nil:
+				fmt.Fprintf(w, "own cgroup limit:        none set, bound by %s\n", *r.LimitedBy)
+			case *r.CgroupLeaf == *r.CgroupEffective:
+				fmt.Fprintf(w, "own cgroup limit:        %s (binding)\n", formatCPUs(*r.CgroupLeaf))
+			default:
+				fmt.Fprintf(w, "own cgroup limit:        %s, bound by %s\n", formatCPUs(*r.CgroupLeaf), *r.LimitedBy)
+			}
+		}
+		if r.LimitedByUnit != nil {
+			fmt.Fprintln(w, "limited by unit:        ", *r.LimitedByUnit)
+		}
+		if r.CgroupQuota != nil {
+			fmt.Fprintf(w, "cgroup quota:            quota=%d period=%d -> %s CPUs\n", *r.CgroupQuota, *r.CgroupPeriod, formatCPUs(*r.CgroupEffective))
+		}
+		if r.CgroupBurstRatio != nil {
+			fmt.Fprintf(w, "cgroup burst limit:      %s (burst ratio %s)\n", formatCPUs(*r.CgroupBurst), formatCPUs(*r.CgroupBurstRatio))
+		}
+	}
+	if verbose {
+		for _, l := range r.CgroupLevels {
+			switch {
+			case l.Limit != 0:
+				fmt.Fprintf(w, "  /%s: quota=%d period=%d -> %s CPUs\n", l.Dir, l.Quota, l.Period, formatCPUs(l.Limit))
+			case l.Quota < 0:
+				fmt.Fprintf(w, "  /%s: quota=max period=%d\n", l.Dir, l.Period)
+			default:
+				fmt.Fprintf(w, "  /%s: none set\n", l.Dir)
+			}
 		}
+	}
+	if r.DetectedRuntime != nil {
+		fmt.Fprintln(w, "detected runtime:       ", *r.DetectedRuntime)
+	}
+	if r.CgroupWeight != nil {
+		// Informational only: weights are not a cap on CPU.
+		fmt.Fprintf(w, "cgroup CPU weight:       %s (relative to default)\n", formatCPUs(*r.CgroupWeight))
+	}
 
-		// Move to the parent directory.
-		currentPath = filepath.Dir(currentPath)
+	if r.ContainerAware && r.Error == nil {
+		if want := recommended(r); want == r.RuntimeGOMAXPROCS {
+			fmt.Fprintf(w, "runtime vs goplay:       agree (%d)\n", want)
+		} else {
+			fmt.Fprintf(w, "runtime vs goplay:       differ (runtime %d, goplay %d)\n", r.RuntimeGOMAXPROCS, want)
+		}
 	}
 
-	if math.IsInf(minLimit, 1) {
-		return 0, nil
+	if r.ContainerMaxProcs != nil {
+		fmt.Fprintf(w, "containermaxprocs:       %s (from %s)\n", *r.ContainerMaxProcs, *r.ContainerMaxSource)
+	} else {
+		fmt.Fprintln(w, "containermaxprocs:       unset")
+	}
+	if r.Error == nil {
+		fmt.Fprintln(w, "GOMAXPROCS by setting:   containermaxprocs=1  containermaxprocs=0  goplay")
+		fmt.Fprintf(w, "                         %-19d  %-19d  %s\n", *r.RuntimeAware, *r.RuntimeUnaware, c.green(strconv.Itoa(recommended(r))))
 	}
 
-	return minLimit, nil
-}
+	fmt.Fprint(w, "cgroup memory limit:     ")
+	if r.Error != nil {
+		fmt.Fprintln(w, *r.Error)
+	} else if r.CgroupMemoryLimit == nil {
+		fmt.Fprintln(w, "unlimited")
+	} else {
+		fmt.Fprintf(w, "%d bytes (%.1f MiB)\n", *r.CgroupMemoryLimit, float64(*r.CgroupMemoryLimit)/(1<<20))
+	}
+	if r.CgroupMemoryHigh != nil {
+		fmt.Fprintf(w, "cgroup memory.high:      %d bytes (%.1f MiB)\n", *r.CgroupMemoryHigh, float64(*r.CgroupMemoryHigh)/(1<<20))
+	}
 
-// calculateV1CPUQuota computes the CPU quota for a given cgroup v1 path.
-func calculateV1CPUQuota(path string) (float64, error) {
-	quotaFile := filepath.Join(path, "cpu.cfs_quota_us")
-	periodFile := filepath.Join(path, "cpu.cfs_period_us")
+	if r.Error == nil {
+		if r.CgroupPidsLimit == nil {
+			fmt.Fprintln(w, "cgroup pids limit:       unlimited")
+		} else {
+			fmt.Fprintln(w, "cgroup pids limit:      ", *r.CgroupPidsLimit)
+		}
+	}
 
-	quota, err := readIntFromFile(quotaFile)
-	if err != nil {
-		return 0, err
+	if r.SuggestedGOMEMLIMIT != nil {
+		if r.CgroupMemoryHigh != nil && (r.CgroupMemoryLimit == nil || *r.CgroupMemoryHigh < *r.CgroupMemoryLimit) {
+			fmt.Fprintf(w, "suggested:               GOMEMLIMIT=%d (relative to memory.high)\n", *r.SuggestedGOMEMLIMIT)
+		} else {
+			fmt.Fprintf(w, "suggested:               GOMEMLIMIT=%d\n", *r.SuggestedGOMEMLIMIT)
+		}
 	}
 
-	// A quota of -1 in v1 means the cgroup has unlimited CPU time.
-	if quota == cgroupV1UnlimitedQuota {
-		return math.Inf(1), nil
+	if r.CgroupThrottled != nil {
+		fmt.Fprintf(w, "cgroup throttling:       %d of %d periods (%.2fs throttled)\n", *r.CgroupThrottled, *r.CgroupPeriods, *r.CgroupThrottledSec)
+	}
+	if r.CgroupCPUUsageSec != nil {
+		fmt.Fprintf(w, "cgroup CPU usage:        %.2fs (cpuacct.usage)\n", *r.CgroupCPUUsageSec)
 	}
 
-	period, err := readIntFromFile(periodFile)
-	if err != nil {
-		return 0, err
+	if r.ResctrlGroup != nil {
+		printResctrl(w, r)
 	}
-	if period == 0 {
-		return 0, fmt.Errorf("cpu.cfs_period_us is zero")
+
+	if r.TopologySMT != nil {
+		printTopology(w, r)
 	}
 
-	return float64(quota) / float64(period), nil
-}
+	if r.DurationMs != nil {
+		fmt.Fprintf(w, "detection took:          %.3fms\n", *r.DurationMs)
+		if verbose {
+			for _, t := range r.FileTimings {
+				fmt.Fprintf(w, "  /%s: %s\n", t.Name, t.Duration)
+			}
+		}
+	}
 
-// calculateV2CPUQuota computes the CPU quota for a given cgroup v2 path.
-func calculateV2CPUQuota(path string) (float64, error) {
-	maxFile := filepath.Join(path, "cpu.max")
+	for _, warning := range r.Warnings {
+		fmt.Fprintln(w, c.red("WARNING: "+warning))
+	}
 
-	content, err := os.ReadFile(maxFile)
-	if err != nil {
-		return 0, err
+	if r.Automaxprocs != nil {
+		printCompare(w, r)
 	}
 
-	parts := strings.Fields(string(content))
-	if len(parts) != 2 {
-		return 0, fmt.Errorf("invalid format in cpu.max: %s", content)
+	if r.SetPrevious != nil {
+		fmt.Fprint(w, "set GOMAXPROCS:          ")
+		if r.SetError != nil {
+			fmt.Fprintln(w, "error:", *r.SetError)
+		} else {
+			fmt.Fprintf(w, "%d -> %d\n", *r.SetPrevious, *r.SetNew)
+		}
 	}
 
-	// If quota is "max", it's unlimited.
-	if parts[0] == "max" {
-		return math.Inf(1), nil
+	if r.CheckMatch != nil {
+		printCheck(w, r)
 	}
 
-	quota, err := strconv.ParseInt(parts[0], 10, 64)
-	if err != nil {
-		return 0, err
+	if r.AssertMatch != nil {
+		printAssert(w, r)
 	}
+}
 
-	period, err := strconv.ParseInt(parts[1], 10, 64)
-	if err != nil {
-		return 0, err
+// printQuiet prints only the recommended GOMAXPROCS so that it can be used as
+// GOMAXPROCS=$(goplay -quiet). Errors and warnings go to stderr.
+func printQuiet(w io.Writer, r report) {
+	for _, w := range r.Warnings {
+		fmt.Fprintln(os.Stderr, "WARNING:", w)
 	}
-	if period == 0 {
-		return 0, fmt.Errorf("period in cpu.max is zero")
+	if r.Error != nil {
+		fmt.Fprintln(os.Stderr, *r.Error)
+		return
 	}
+	fmt.Fprintln(w, recommended(r))
+}
 
-	return float64(quota) / float64(period), nil
+// printMillicpu prints only the effective CPU limit in millicores, as
+// Kubernetes resources.limits.cpu expresses it, or "unlimited". Errors and
+// warnings go to stderr.
+func printMillicpu(w io.Writer, r report) {
+	for _, w := range r.Warnings {
+		fmt.Fprintln(os.Stderr, "WARNING:", w)
+	}
+	if r.Error != nil {
+		fmt.Fprintln(os.Stderr, *r.Error)
+		return
+	}
+	if r.CgroupEffective == nil {
+		fmt.Fprintln(w, "unlimited")
+		return
+	}
+	fmt.Fprintf(w, "%d millicores\n", int64(math.Round(*r.CgroupEffective*1000)))
 }
 
-// readIntFromFile is a helper to read an integer from a file.
-func readIntFromFile(filePath string) (int64, error) {
-	content, err := os.ReadFile(filePath)
-	if err != nil {
-		return 0, err
+// formatCPUs formats a number of CPUs without trailing zeros, e.g. "2" or
+// "1.5", rounded to microsecond-quota precision.
+func formatCPUs(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 6, 64)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// tsvColumns is the header of -format tsv. Columns may be appended but never
+// reordered.
+var tsvColumns = []string{"numcpu", "gomaxprocs_env", "affinity", "runtime_gomaxprocs", "effective", "adjusted"}
+
+// printTSV prints a header row and a data row of tab-separated values. Unset
+// values are empty.
+func printTSV(w io.Writer, r report) {
+	row := []string{
+		strconv.Itoa(r.NumCPU),
+		tsvValue(r.GOMAXPROCSEnv, func(v string) string { return v }),
+		tsvValue(r.SchedAffinityCount, strconv.Itoa),
+		strconv.Itoa(r.RuntimeGOMAXPROCS),
+		tsvValue(r.CgroupEffective, func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }),
+		tsvValue(r.CgroupAdjusted, strconv.Itoa),
 	}
-	val, err := strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64)
-	if err != nil {
-		return 0, err
+	fmt.Fprintln(w, strings.Join(tsvColumns, "\t"))
+	fmt.Fprintln(w, strings.Join(row, "\t"))
+}
+
+// tsvValue formats v with format, or returns "" if v is nil. Tabs and
+// newlines are replaced so that a value cannot break the row.
+func tsvValue[T any](v *T, format func(T) string) string {
+	if v == nil {
+		return ""
+	}
+	return strings.NewReplacer("\t", " ", "\n", " ").Replace(format(*v))
+}
+
+func printJSON(w io.Writer, r report) {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(r); err != nil {
+		fmt.Fprintln(os.Stderr, "error encoding json:", err)
 	}
-	return val, nil
 }
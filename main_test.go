@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/schmichael/goplay/cgroup"
+)
+
+// runMainEnv makes the test binary run main instead of the tests, so that
+// exit codes can be observed from a subprocess.
+const runMainEnv = "GOPLAY_TEST_RUN_MAIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(runMainEnv) == "1" {
+		os.Args = append([]string{"goplay"}, os.Args[1:]...)
+		main()
+		return
+	}
+	os.Exit(m.Run())
+}
+
+// writeTree writes files, keyed by slash-separated paths, to a new
+// directory and returns it.
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+// newTestDetector returns a Detector reading from files written by
+// writeTree.
+func newTestDetector(t *testing.T, files map[string]string) *cgroup.Detector {
+	t.Helper()
+	return &cgroup.Detector{FS: os.DirFS(writeTree(t, files))}
+}
+
+// v2Tree is a cgroup v2 snapshot limited to 2.5 CPUs.
+var v2Tree = map[string]string{
+	"proc/self/cgroup":                 "0::/kube\n",
+	"sys/fs/cgroup/cgroup.controllers": "cpu memory pids\n",
+	"sys/fs/cgroup/kube/cpu.max":       "250000 100000\n",
+}
+
+func TestExitCodes(t *testing.T) {
+	snapshot := writeTree(t, v2Tree)
+	empty := t.TempDir()
+	// /proc/self/cgroup is a directory, so reading it fails.
+	unreadable := writeTree(t, map[string]string{
+		"proc/self/cgroup/x":               "",
+		"sys/fs/cgroup/cgroup.controllers": "cpu\n",
+	})
+
+	cases := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"ok", []string{"-root", snapshot}, exitOK},
+		{"help", []string{"-h"}, exitOK},
+		{"not in cgroup", []string{"-root", empty}, exitOK},
+		{"require cgroup", []string{"-root", empty, "-require-cgroup"}, exitNotInCgroup},
+		{"detection error", []string{"-root", unreadable}, exitError},
+		{"assert mismatch", []string{"-root", snapshot, "-assert-cpus", "4"}, exitError},
+		{"unknown flag", []string{"-bogus"}, exitUsage},
+		{"invalid min", []string{"-min", "0"}, exitUsage},
+		{"max below min", []string{"-min", "4", "-max", "2"}, exitUsage},
+		{"interval with watch", []string{"-interval", "1s", "-watch"}, exitUsage},
+		{"interval with format", []string{"-interval", "1s", "-format", "tsv"}, exitUsage},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd := exec.Command(os.Args[0], tc.args...)
+			cmd.Env = append(os.Environ(), runMainEnv+"=1")
+			err := cmd.Run()
+			got := 0
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				got = exitErr.ExitCode()
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("goplay %v exited %d, want %d", tc.args, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestExitCodesDistinct(t *testing.T) {
+	codes := map[int]string{}
+	for name, code := range map[string]int{
+		"exitOK":          exitOK,
+		"exitError":       exitError,
+		"exitNotInCgroup": exitNotInCgroup,
+		"exitUsage":       exitUsage,
+	} {
+		if other, ok := codes[code]; ok {
+			t.Errorf("%s and %s are both %d", name, other, code)
+		}
+		codes[code] = name
+	}
+}
+
+// stubAffinity makes getAffinity report n CPUs for the rest of the test.
+func stubAffinity(t *testing.T, n int) {
+	t.Helper()
+	orig := getAffinity
+	t.Cleanup(func() { getAffinity = orig })
+	getAffinity = func(pid int) (int, string, error) {
+		return n, "[stub]", nil
+	}
+}
+
+func TestCollectEmptyAffinity(t *testing.T) {
+	stubAffinity(t, 0)
+	r := collect(newTestDetector(t, v2Tree))
+	if !slices.Contains(r.Warnings, "sched_getaffinity(2) reported no CPUs in the affinity mask") {
+		t.Errorf("warnings = %q, want the empty affinity mask reported", r.Warnings)
+	}
+	if r.SchedAffinityCount == nil || *r.SchedAffinityCount != 0 {
+		t.Errorf("schedAffinityCount = %v, want 0", r.SchedAffinityCount)
+	}
+}
+
+func TestCollectSnapshotIgnoresAffinity(t *testing.T) {
+	// The host's mask says nothing about a snapshot's 2.5 CPU quota.
+	stubAffinity(t, 1)
+	r := collect(newTestDetector(t, v2Tree))
+	for _, w := range r.Warnings {
+		if strings.Contains(w, "exceeds available CPUs") {
+			t.Errorf("unexpected warning for a snapshot: %q", w)
+		}
+	}
+}
+
+func TestFormatCPUs(t *testing.T) {
+	cases := []struct {
+		v    float64
+		want string
+	}{
+		{2, "2"},
+		{1.5, "1.5"},
+		{0.25, "0.25"},
+		{10, "10"},
+		{100, "100"},
+		{2.000001, "2.000001"},
+		{1.0 / 3, "0.333333"},
+		{0, "0"},
+	}
+	for _, tc := range cases {
+		if got := formatCPUs(tc.v); got != tc.want {
+			t.Errorf("formatCPUs(%v) = %q, want %q", tc.v, got, tc.want)
+		}
+	}
+}
+
+// TestRootStaysInSnapshot checks that an untrusted -root snapshot cannot make
+// goplay read files outside of it.
+func TestRootStaysInSnapshot(t *testing.T) {
+	// A quota of 1 CPU outside the snapshot, which must not be found.
+	outside := writeTree(t, map[string]string{"cpu.max": "100000 100000\n"})
+
+	cases := []struct {
+		name  string
+		files map[string]string
+		link  string // symlinked to outside, if not empty
+	}{
+		{
+			name: "dot-dot cgroup path",
+			files: map[string]string{
+				"proc/self/cgroup":                 "0::/../../../../" + filepath.ToSlash(outside) + "\n",
+				"sys/fs/cgroup/cgroup.controllers": "cpu\n",
+			},
+		},
+		{
+			name: "dot-dot inside cgroup path",
+			files: map[string]string{
+				"proc/self/cgroup":                 "0::/kube/../../../../../../" + filepath.ToSlash(outside) + "\n",
+				"sys/fs/cgroup/cgroup.controllers": "cpu\n",
+			},
+		},
+		{
+			name: "symlinked cgroup directory",
+			files: map[string]string{
+				"proc/self/cgroup":                 "0::/kube\n",
+				"sys/fs/cgroup/cgroup.controllers": "cpu\n",
+			},
+			link: "sys/fs/cgroup/kube",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			root := writeTree(t, tc.files)
+			if tc.link != "" {
+				if err := os.Symlink(outside, filepath.Join(root, filepath.FromSlash(tc.link))); err != nil {
+					t.Skip("symlinks unsupported:", err)
+				}
+			}
+
+			var buf bytes.Buffer
+			run(&buf, []string{"goplay", "-root", root, "-json"})
+			var r struct {
+				CgroupEffective *float64 `json:"cgroupEffective"`
+			}
+			if err := json.Unmarshal(buf.Bytes(), &r); err != nil {
+				t.Fatal(err)
+			}
+			if r.CgroupEffective != nil {
+				t.Errorf("cgroupEffective = %g read from outside the snapshot", *r.CgroupEffective)
+			}
+		})
+	}
+}
+
+func TestRuntimeGOMAXPROCSIgnoresWalkMode(t *testing.T) {
+	// The leaf allows 3 CPUs but its parent only 1.5, which is all the
+	// runtime looks at.
+	tree := map[string]string{
+		"proc/self/cgroup":                 "0::/pod/app\n",
+		"sys/fs/cgroup/cgroup.controllers": "cpu\n",
+		"sys/fs/cgroup/pod/cpu.max":        "150000 100000\n",
+		"sys/fs/cgroup/pod/app/cpu.max":    "300000 100000\n",
+	}
+	for _, mode := range []string{"leaf-only", "nearest"} {
+		t.Run(mode, func(t *testing.T) {
+			d := newTestDetector(t, tree)
+			d.LeafOnly, d.Nearest = mode == "leaf-only", mode == "nearest"
+			r := collect(d)
+			if r.CgroupEffective == nil || *r.CgroupEffective != 3 {
+				t.Fatalf("cgroupEffective = %v, want 3", r.CgroupEffective)
+			}
+			r.NumCPU = 8
+			if aware, _ := runtimeGOMAXPROCS(r); aware != 2 {
+				t.Errorf("runtimeGOMAXPROCS = %d, want 2", aware)
+			}
+			proposalStrict(&r)
+			if r.CgroupAdjusted == nil || *r.CgroupAdjusted != 2 {
+				t.Errorf("proposalStrict adjusted = %v, want 2", r.CgroupAdjusted)
+			}
+		})
+	}
+}
+
+func TestDoctorNotInCgroup(t *testing.T) {
+	stubAffinity(t, 4)
+	for _, c := range doctorChecks(newTestDetector(t, nil)) {
+		if c.status == fail {
+			t.Errorf("%s: %s %s, want no failure outside a cgroup", c.name, c.status, c.detail)
+		}
+	}
+}
+
+func TestDoctorPID(t *testing.T) {
+	stubAffinity(t, 4)
+	d := newTestDetector(t, map[string]string{
+		"proc/1/cgroup":                    "0::/kube\n",
+		"sys/fs/cgroup/cgroup.controllers": "cpu\n",
+	})
+	d.PID = 1
+	checks := doctorChecks(d)
+	if !slices.ContainsFunc(checks, func(c doctorCheck) bool { return c.name == "/proc/1/cgroup" && c.status == pass }) {
+		t.Errorf("checks = %v, want /proc/1/cgroup to pass", checks)
+	}
+}
+
+func TestUseColorDevNull(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	f, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if useColor(f) {
+		t.Errorf("useColor(%s) = true, want false", os.DevNull)
+	}
+}
+
+func TestAutomaxprocsNoQuota(t *testing.T) {
+	// Without a quota automaxprocs leaves the runtime's default alone, which
+	// Go 1.25 may already have lowered below NumCPU.
+	r := report{NumCPU: 8, RuntimeGOMAXPROCS: 5}
+	if got, _ := automaxprocs(r); got != 5 {
+		t.Errorf("automaxprocs = %d, want the current GOMAXPROCS 5", got)
+	}
+}
+
+func TestSetGOMAXPROCSFromReport(t *testing.T) {
+	t.Setenv("GOMAXPROCS", "")
+	os.Unsetenv("GOMAXPROCS")
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(-1))
+
+	root := writeTree(t, v2Tree)
+	d := &cgroup.Detector{FS: os.DirFS(root)}
+	r := collect(d)
+	// A resize after detection is not applied, since r does not report it.
+	if err := os.WriteFile(filepath.Join(root, "sys/fs/cgroup/kube/cpu.max"), []byte("800000 100000\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	setGOMAXPROCS(&r, false)
+	if r.SetError != nil {
+		t.Fatal(*r.SetError)
+	}
+	if r.SetNew == nil || *r.SetNew != 3 {
+		t.Errorf("setNew = %v, want 3", r.SetNew)
+	}
+	if got := runtime.GOMAXPROCS(-1); got != 3 {
+		t.Errorf("runtime.GOMAXPROCS(-1) = %d, want 3", got)
+	}
+}
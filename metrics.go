@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/schmichael/goplay/cgroup"
+)
+
+// serveMetrics serves detection results as Prometheus gauges on addr. Values
+// are re-detected on each scrape so resizes are reflected.
+func serveMetrics(addr string, d *cgroup.Detector) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metricsHandler(d))
+	return http.ListenAndServe(addr, mux)
+}
+
+// metricsHandler returns a handler serving only goplay's gauges, without the
+// client library's default Go runtime and process collectors.
+func metricsHandler(d *cgroup.Detector) http.Handler {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(&metricsCollector{d: d})
+	return promhttp.HandlerFor(reg, promhttp.HandlerOpts{})
+}
+
+// gauge is a gauge and how to read its value from a report.
+type gauge struct {
+	desc  *prometheus.Desc
+	value func(r report) (float64, bool)
+}
+
+// gauges are the metrics served with -metrics.
+var gauges = []gauge{
+	{
+		prometheus.NewDesc("goplay_num_cpu", "Logical CPUs usable by the process, from runtime.NumCPU().", nil, nil),
+		func(r report) (float64, bool) { return float64(r.NumCPU), true },
+	},
+	{
+		prometheus.NewDesc("goplay_sched_affinity_cpus", "CPUs in the process's sched_getaffinity(2) mask.", nil, nil),
+		func(r report) (float64, bool) { return gaugeValue(r.SchedAffinityCount) },
+	},
+	{
+		prometheus.NewDesc("goplay_runtime_gomaxprocs", "Current runtime.GOMAXPROCS(-1).", nil, nil),
+		func(r report) (float64, bool) { return float64(r.RuntimeGOMAXPROCS), true },
+	},
+	{
+		prometheus.NewDesc("goplay_cgroup_effective_cpu", "Effective cgroup CPU limit in CPUs.", nil, nil),
+		func(r report) (float64, bool) { return gaugeValue(r.CgroupEffective) },
+	},
+	{
+		prometheus.NewDesc("goplay_cgroup_adjusted_gomaxprocs", "GOMAXPROCS adjusted from the cgroup CPU limit, ignoring $GOMAXPROCS.", nil, nil),
+		func(r report) (float64, bool) { return gaugeValue(r.DetectedAdjusted) },
+	},
+}
+
+// gaugeValue returns *v, or false if v is nil so that an absent limit is an
+// absent series rather than a misleading zero.
+func gaugeValue[T int | float64](v *T) (float64, bool) {
+	if v == nil {
+		return 0, false
+	}
+	return float64(*v), true
+}
+
+// metricsCollector detects the limits once per scrape and reports them as
+// gauges. Unlike a GaugeFunc per metric, it can omit the gauges whose limit
+// is absent and reads the cgroup files only once.
+type metricsCollector struct {
+	d *cgroup.Detector
+}
+
+func (c *metricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, g := range gauges {
+		ch <- g.desc
+	}
+}
+
+func (c *metricsCollector) Collect(ch chan<- prometheus.Metric) {
+	r := collect(c.d)
+	for _, g := range gauges {
+		if v, ok := g.value(r); ok {
+			ch <- prometheus.MustNewConstMetric(g.desc, prometheus.GaugeValue, v)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	unlimited := map[string]string{
+		"proc/self/cgroup":                 "0::/kube\n",
+		"sys/fs/cgroup/cgroup.controllers": "cpu\n",
+		"sys/fs/cgroup/kube/cpu.max":       "max 100000\n",
+	}
+	cases := []struct {
+		name          string
+		files         map[string]string
+		gomaxprocs    string
+		want, missing []string
+	}{
+		{"limited", v2Tree, "", []string{"goplay_cgroup_effective_cpu 2.5\n", "goplay_cgroup_adjusted_gomaxprocs 3\n", "goplay_num_cpu "}, nil},
+		{"unlimited", unlimited, "", []string{"goplay_num_cpu "}, []string{"goplay_cgroup_effective_cpu", "goplay_cgroup_adjusted_gomaxprocs"}},
+		// $GOMAXPROCS overrides the adjusted value, but not the gauge.
+		{"env override", v2Tree, "7", []string{"goplay_cgroup_adjusted_gomaxprocs 3\n"}, nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("GOMAXPROCS", tc.gomaxprocs)
+			d := newTestDetector(t, tc.files)
+			srv := httptest.NewServer(metricsHandler(d))
+			defer srv.Close()
+
+			resp, err := srv.Client().Get(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range tc.want {
+				if !strings.Contains(string(body), s) {
+					t.Errorf("metrics are missing %q:\n%s", s, body)
+				}
+			}
+			for _, s := range tc.missing {
+				if strings.Contains(string(body), s) {
+					t.Errorf("metrics unexpectedly contain %q:\n%s", s, body)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/schmichael/goplay/cgroup"
+)
+
+// poll re-runs detection every interval, printing one timestamped line per
+// iteration, until count iterations have run (forever if count is 0) or it is
+// interrupted or terminated.
+func poll(w io.Writer, d *cgroup.Detector, interval time.Duration, count int, jsonOut bool) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for i := 0; count == 0 || i < count; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+		}
+
+		r := collect(d)
+		if jsonOut {
+			if err := printJSONLine(w, r); err != nil {
+				return err
+			}
+		} else {
+			fmt.Fprintln(w, time.Now().UTC().Format(time.RFC3339), pollLine(r))
+		}
+	}
+	return nil
+}
+
+// printJSONLine prints r as a single line of JSON stamped with the current
+// time, so that -interval and -watch emit a stream of newline-delimited JSON
+// (NDJSON). Each line is written to w as soon as it is encoded.
+func printJSONLine(w io.Writer, r report) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	r.Time = &now
+	return json.NewEncoder(w).Encode(r)
+}
+
+// pollLine summarizes r on a single line.
+func pollLine(r report) string {
+	if r.Error != nil {
+		return "error: " + *r.Error
+	}
+	if r.CgroupEffective == nil && r.InCgroup {
+		return fmt.Sprintf("effective=unlimited gomaxprocs=%d", r.RuntimeGOMAXPROCS)
+	}
+	if r.CgroupEffective == nil {
+		return fmt.Sprintf("effective=none gomaxprocs=%d", r.RuntimeGOMAXPROCS)
+	}
+	return fmt.Sprintf("effective=%s adjusted=%d gomaxprocs=%d", formatCPUs(*r.CgroupEffective), *r.CgroupAdjusted, r.RuntimeGOMAXPROCS)
+}
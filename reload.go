@@ -0,0 +1,26 @@
+package main
+
+import (
+	"log"
+
+	"github.com/schmichael/goplay/cgroup"
+)
+
+// reload re-runs detection, as on SIGHUP, and re-applies GOMAXPROCS if set
+// is true. The transition is logged to stderr.
+func reload(d *cgroup.Detector, set, force bool) report {
+	r := collect(d)
+	if set {
+		setGOMAXPROCS(&r, force)
+	}
+
+	switch {
+	case r.SetError != nil:
+		log.Printf("SIGHUP: %s, not setting GOMAXPROCS: %s", pollLine(r), *r.SetError)
+	case r.SetNew != nil:
+		log.Printf("SIGHUP: %s, GOMAXPROCS %d -> %d", pollLine(r), *r.SetPrevious, *r.SetNew)
+	default:
+		log.Printf("SIGHUP: %s", pollLine(r))
+	}
+	return r
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/schmichael/goplay/cgroup"
+	"github.com/schmichael/goplay/hw"
+)
+
+// resctrl records the resctrl group of the process in r. It is informational
+// only and never affects GOMAXPROCS.
+func resctrl(d *cgroup.Detector, r *report) {
+	rc, ok, err := hw.ReadResctrl(d.FS, d.PID)
+	if err != nil {
+		r.Warnings = append(r.Warnings, "reading resctrl group: "+err.Error())
+		return
+	}
+	none := "none"
+	r.ResctrlGroup = &none
+	if ok {
+		r.ResctrlGroup = &rc.Group
+		r.ResctrlSchemata = rc.Schemata
+	}
+}
+
+// printResctrl prints the resctrl group recorded by resctrl.
+func printResctrl(w io.Writer, r report) {
+	fmt.Fprintln(w, "resctrl (experimental): ", *r.ResctrlGroup)
+	for _, line := range r.ResctrlSchemata {
+		fmt.Fprintf(w, "  %s\n", line)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// TestSchemaGolden locks the field names, order, and types of the -json
+// output. If it fails because of a rename or removal, bump schemaVersion
+// and rerun with -update.
+func TestSchemaGolden(t *testing.T) {
+	var buf bytes.Buffer
+	printJSON(&buf, report{SchemaVersion: schemaVersion, Warnings: []string{}})
+
+	golden := filepath.Join("testdata", "schema.golden")
+	if *update {
+		if err := os.WriteFile(golden, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("-json output changed; bump schemaVersion if a field was renamed, removed, or changed meaning, then rerun with -update.\ngot:\n%s\nwant:\n%s", buf.Bytes(), want)
+	}
+}
+
+// TestSchemaKeysAlwaysPresent checks that a detected report encodes every
+// field in the golden schema, even those that are unset.
+func TestSchemaKeysAlwaysPresent(t *testing.T) {
+	d := newTestDetector(t, v2Tree)
+	var buf bytes.Buffer
+	printJSON(&buf, collect(d))
+
+	got, want := jsonKeys(t, buf.Bytes()), jsonKeys(t, mustReadFile(t, filepath.Join("testdata", "schema.golden")))
+	if !slices.Equal(got, want) {
+		t.Errorf("keys = %q, want %q", got, want)
+	}
+	var v struct {
+		SchemaVersion int `json:"schemaVersion"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.SchemaVersion != schemaVersion {
+		t.Errorf("schemaVersion = %d, want %d", v.SchemaVersion, schemaVersion)
+	}
+}
+
+// jsonKeys returns the top-level keys of the JSON object b, sorted.
+func jsonKeys(t *testing.T, b []byte) []string {
+	t.Helper()
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		t.Fatal(err)
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func mustReadFile(t *testing.T, name string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
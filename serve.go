@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/schmichael/goplay/cgroup"
+)
+
+// shutdownTimeout is how long in-flight requests have to finish on shutdown.
+const shutdownTimeout = 5 * time.Second
+
+// serve serves detection results as JSON on GET /cpu, and applies the
+// adjusted GOMAXPROCS on POST /apply, until interrupted or terminated. If set
+// is true it also applies it on startup and on SIGHUP.
+func serve(addr string, d *cgroup.Detector, set, force bool) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /cpu", func(w http.ResponseWriter, req *http.Request) {
+		writeJSON(w, http.StatusOK, collect(d))
+	})
+	mux.HandleFunc("POST /apply", func(w http.ResponseWriter, req *http.Request) {
+		r := collect(d)
+		setGOMAXPROCS(&r, force)
+		status := http.StatusOK
+		if r.SetError != nil {
+			status = http.StatusConflict
+		}
+		writeJSON(w, status, r)
+	})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	if set {
+		r := collect(d)
+		setGOMAXPROCS(&r, force)
+		if r.SetError != nil {
+			log.Println("not setting GOMAXPROCS:", *r.SetError)
+		}
+	}
+
+	srv := &http.Server{Addr: addr, Handler: logRequests(mux)}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.ListenAndServe()
+	}()
+
+wait:
+	for {
+		select {
+		case err := <-errc:
+			return err
+		case <-hup:
+			reload(d, set, force)
+		case <-ctx.Done():
+			break wait
+		}
+	}
+
+	log.Println("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// writeJSON writes r as the JSON response body with the given status.
+func writeJSON(w http.ResponseWriter, status int, r report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(r); err != nil {
+		log.Println("error encoding json:", err)
+	}
+}
+
+// statusRecorder records the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// logRequests logs each request's method, path, status, and duration to
+// stderr.
+func logRequests(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(rec, req)
+		log.Printf("%s %s %d %s", req.Method, req.URL.Path, rec.status, time.Since(start))
+	})
+}
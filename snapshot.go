@@ -0,0 +1,58 @@
+package main
+
+import (
+	"archive/zip"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// openSnapshot opens the captured tree at root for -root: either a directory
+// or a zip archive of one, such as a support bundle. The returned closer
+// releases it.
+func openSnapshot(root string) (fs.FS, io.Closer, error) {
+	if !strings.HasSuffix(strings.ToLower(root), ".zip") {
+		// Snapshots may come from untrusted hosts, so don't let symlinks
+		// in them resolve to files outside of root.
+		dir, err := os.OpenRoot(root)
+		if err != nil {
+			return nil, nil, err
+		}
+		return dir.FS(), dir, nil
+	}
+
+	// Entries named with the host's absolute layout, e.g.
+	// "/sys/fs/cgroup/cpu.max", are opened relative to the archive's root,
+	// and symlinks are not followed.
+	z, err := zip.OpenReader(root)
+	if err != nil {
+		return nil, nil, err
+	}
+	fsys, err := snapshotRoot(z)
+	if err != nil {
+		z.Close()
+		return nil, nil, err
+	}
+	return fsys, z, nil
+}
+
+// snapshotRoot returns the directory of fsys containing the captured "sys"
+// and "proc" trees, descending into a single top-level directory, as
+// archives of a directory usually have, if needed.
+func snapshotRoot(fsys fs.FS) (fs.FS, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.Name() == "sys" || e.Name() == "proc" {
+			return fsys, nil
+		}
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		return fs.Sub(fsys, entries[0].Name())
+	}
+	return nil, errors.New("no sys or proc directory in snapshot")
+}
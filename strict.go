@@ -0,0 +1,26 @@
+package main
+
+// proposalStrict replaces the adjusted GOMAXPROCS in r with the one the Go
+// 1.25 runtime chooses, as specified in golang/go#73193: the ceiling of the
+// minimum quota/period across the cgroup hierarchy, at least 2 but never
+// more than runtime.NumCPU(). Cpusets are not read since NumCPU already
+// reflects the affinity mask, and burst, weights, -min, and -round do not
+// apply. A valid $GOMAXPROCS and GODEBUG=containermaxprocs=0 are respected.
+func proposalStrict(r *report) {
+	if r.AdjustedSource != nil && *r.AdjustedSource == "env" {
+		return
+	}
+	r.CgroupAdjusted = nil
+	r.AdjustedSource = nil
+	if r.ContainerMaxProcs != nil && *r.ContainerMaxProcs == "0" {
+		return
+	}
+
+	procs, ok := runtimeCgroupGOMAXPROCS(*r)
+	if !ok {
+		return
+	}
+	src := "proposal"
+	r.CgroupAdjusted = &procs
+	r.AdjustedSource = &src
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package main
+
+import "os"
+
+// isTerminal is only implemented on Linux, so output is never colored
+// elsewhere.
+func isTerminal(f *os.File) bool {
+	return false
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"io/fs"
+	"time"
+)
+
+// fileTiming is how long opening and reading one file took.
+type fileTiming struct {
+	Name     string
+	Duration time.Duration
+}
+
+// timingFS records how long each file opened through it takes to open, read,
+// and close, to diagnose a slow sysfs.
+type timingFS struct {
+	fsys  fs.FS
+	reads []fileTiming
+}
+
+func (t *timingFS) Open(name string) (fs.File, error) {
+	start := time.Now()
+	f, err := t.fsys.Open(name)
+	if err != nil {
+		t.reads = append(t.reads, fileTiming{Name: name, Duration: time.Since(start)})
+		return nil, err
+	}
+	return &timedFile{File: f, fs: t, name: name, elapsed: time.Since(start)}, nil
+}
+
+// timedFile accumulates the time spent in its reads and records the total
+// on Close.
+type timedFile struct {
+	fs.File
+	fs      *timingFS
+	name    string
+	elapsed time.Duration
+}
+
+func (f *timedFile) Read(p []byte) (int, error) {
+	start := time.Now()
+	n, err := f.File.Read(p)
+	f.elapsed += time.Since(start)
+	return n, err
+}
+
+func (f *timedFile) Close() error {
+	start := time.Now()
+	err := f.File.Close()
+	f.fs.reads = append(f.fs.reads, fileTiming{Name: f.name, Duration: f.elapsed + time.Since(start)})
+	return err
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/schmichael/goplay/cgroup"
+	"github.com/schmichael/goplay/hw"
+)
+
+// topology records the host's CPU topology in r. It is informational only
+// and never affects GOMAXPROCS.
+func topology(d *cgroup.Detector, r *report) {
+	t, err := hw.ReadTopology(d.FS)
+	if err != nil {
+		r.Warnings = append(r.Warnings, "reading CPU topology: "+err.Error())
+		return
+	}
+	smt := t.SMT()
+	r.TopologyLogicalCPUs = &t.LogicalCPUs
+	r.TopologyPhysicalCores = &t.PhysicalCores
+	r.TopologySockets = &t.Sockets
+	r.TopologySMT = &smt
+}
+
+// printTopology prints the topology recorded by topology.
+func printTopology(w io.Writer, r report) {
+	fmt.Fprintf(w, "CPU topology:            physical cores: %d, logical CPUs: %d, sockets: %d, SMT factor: %s\n",
+		*r.TopologyPhysicalCores, *r.TopologyLogicalCPUs, *r.TopologySockets, formatCPUs(*r.TopologySMT))
+}
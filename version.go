@@ -0,0 +1,74 @@
+package main
+
+import (
+	"go/version"
+	"math"
+	"os"
+	"runtime/debug"
+	"strings"
+)
+
+// containerAware reports whether the Go runtime of version v (as returned by
+// runtime.Version) sets the default GOMAXPROCS from the cgroup CPU limit,
+// which Go 1.25 and newer do.
+func containerAware(v string) bool {
+	// Development versions look like "devel go1.26-abcdef Tue Jun 3 ...".
+	if rest, ok := strings.CutPrefix(v, "devel "); ok {
+		v, _, _ = strings.Cut(rest, "-")
+	}
+	return version.Compare(v, "go1.25") >= 0
+}
+
+// godebug returns the value of the GODEBUG setting name and where it came
+// from: "$GODEBUG", or "go.mod" for the default the main module's go version
+// implies. It returns "" if the setting is not set either way.
+func godebug(name string) (value, source string) {
+	// Later settings take precedence.
+	settings := strings.Split(os.Getenv("GODEBUG"), ",")
+	for i := len(settings) - 1; i >= 0; i-- {
+		if k, v, ok := strings.Cut(settings[i], "="); ok && k == name {
+			return v, "$GODEBUG"
+		}
+	}
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			if s.Key != "DefaultGODEBUG" {
+				continue
+			}
+			for _, setting := range strings.Split(s.Value, ",") {
+				if k, v, ok := strings.Cut(setting, "="); ok && k == name {
+					return v, "go.mod"
+				}
+			}
+		}
+	}
+	return "", ""
+}
+
+// runtimeGOMAXPROCS returns the default GOMAXPROCS the Go 1.25 runtime
+// chooses from r with GODEBUG=containermaxprocs=1 and with
+// containermaxprocs=0. A valid $GOMAXPROCS takes precedence over both.
+func runtimeGOMAXPROCS(r report) (aware, unaware int) {
+	if r.AdjustedSource != nil && *r.AdjustedSource == "env" {
+		return *r.CgroupAdjusted, *r.CgroupAdjusted
+	}
+	unaware = r.NumCPU
+	aware, ok := runtimeCgroupGOMAXPROCS(r)
+	if !ok {
+		return unaware, unaware
+	}
+	return aware, unaware
+}
+
+// runtimeCgroupGOMAXPROCS returns the GOMAXPROCS the Go 1.25 runtime derives
+// from r's cgroup limit: the ceiling of the minimum quota/period across the
+// whole hierarchy, at least 2 but never more than runtime.NumCPU(). Like the
+// runtime it ignores -leaf-only, -nearest, -cpu-limit-env, cpusets, -min, and
+// -round. It returns false if no level sets a quota.
+func runtimeCgroupGOMAXPROCS(r report) (int, bool) {
+	if r.CgroupHierarchy == 0 {
+		return 0, false
+	}
+	return min(r.NumCPU, max(2, int(math.Ceil(r.CgroupHierarchy)))), true
+}
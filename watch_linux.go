@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/schmichael/goplay/cgroup"
+)
+
+// watchDebounce is how long to wait for cgroup file writes to settle before
+// re-detecting. Resizes often rewrite several files in quick succession.
+const watchDebounce = 250 * time.Millisecond
+
+// watch prints the report, then reprints it whenever the CPU limit changes
+// until interrupted or terminated. If set is true, GOMAXPROCS is re-applied on
+// each change. SIGHUP forces a re-detection and, with set, re-applies
+// GOMAXPROCS.
+//
+// Only the limit files that exist when watch starts are watched, so a file
+// created later, such as a cpu.max.burst written after startup, is only
+// picked up on SIGHUP or the next change to a watched file.
+func watch(d *cgroup.Detector, set, force bool, printReport func(report)) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, unix.SIGTERM)
+	defer stop()
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, unix.SIGHUP)
+	defer signal.Stop(hup)
+
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC)
+	if err != nil {
+		return fmt.Errorf("inotify_init1: %w", err)
+	}
+	defer unix.Close(fd)
+
+	files, err := d.CPUFiles()
+	if err != nil {
+		return fmt.Errorf("finding cgroup cpu files: %w", err)
+	}
+	watched := 0
+	for _, f := range files {
+		if _, err := unix.InotifyAddWatch(fd, "/"+f, unix.IN_MODIFY); err != nil {
+			fmt.Fprintf(os.Stderr, "not watching /%s: %v\n", f, err)
+			continue
+		}
+		watched++
+	}
+	if watched == 0 {
+		return fmt.Errorf("no cgroup cpu files to watch")
+	}
+
+	events := make(chan struct{}, 1)
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			if _, err := unix.Read(fd, buf); err != nil {
+				if err == unix.EINTR {
+					continue
+				}
+				return
+			}
+			select {
+			case events <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	last := collect(d)
+	if set {
+		setGOMAXPROCS(&last, force)
+	}
+	printReport(last)
+
+	debounce := time.NewTimer(0)
+	<-debounce.C
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-events:
+			debounce.Reset(watchDebounce)
+		case <-hup:
+			last = reload(d, set, force)
+			printReport(last)
+		case <-debounce.C:
+			r := collect(d)
+			if limitsChanged(last, r) {
+				if set {
+					setGOMAXPROCS(&r, force)
+				}
+				printReport(r)
+			}
+			last = r
+		}
+	}
+}
+
+// limitsChanged reports whether the detected CPU limits differ between two
+// reports.
+func limitsChanged(a, b report) bool {
+	return !equalPtr(a.CgroupEffective, b.CgroupEffective) ||
+		!equalPtr(a.CgroupBurst, b.CgroupBurst) ||
+		!equalPtr(a.CgroupAdjusted, b.CgroupAdjusted) ||
+		!equalPtr(a.Error, b.Error)
+}
+
+func equalPtr[T comparable](a, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
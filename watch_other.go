@@ -0,0 +1,14 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+
+	"github.com/schmichael/goplay/cgroup"
+)
+
+// watch is only implemented on Linux, where it uses inotify.
+func watch(d *cgroup.Detector, set, force bool, printReport func(report)) error {
+	return errors.New("-watch unsupported on this platform")
+}